@@ -0,0 +1,114 @@
+package orderedmap
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The kind of change that an Op makes to a map.
+type OpType int
+
+const (
+	// Add a key onto the end of the map, or update its value in place if it
+	// already exists.
+	OpAdd OpType = iota
+	// Delete a key and its data from the map.  The key must exist.
+	OpDelete
+	// Move an existing key to Position, counted after it has been removed
+	// from its current spot.
+	OpMove
+	// Rename an existing key to NewKey, keeping its value and position.
+	// NewKey must not already be in use.
+	OpRename
+)
+
+// A single change to a map, used with ApplyBatch.  Only the fields needed by
+// the Type of change are used.
+type Op struct {
+	Type     OpType
+	Key      string
+	Value    interface{}
+	Position int
+	NewKey   string
+}
+
+// Apply a list of changes to the map, in order, as a single step.  If any of
+// the changes is invalid, an error describing it is returned and the map is
+// left exactly as it was before ApplyBatch was called.
+func (m *OrderedMap) ApplyBatch(ops []Op) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	data := make(map[string]interface{}, len(m.data))
+	for k, v := range m.data {
+		data[k] = v
+	}
+	order := make([]string, len(m.order))
+	copy(order, m.order)
+	var insertion []string
+	if m.insertion != nil {
+		insertion = make([]string, len(m.insertion))
+		copy(insertion, m.insertion)
+	}
+	var added map[string]time.Time
+	if m.added != nil {
+		added = make(map[string]time.Time, len(m.added))
+		for k, v := range m.added {
+			added[k] = v
+		}
+	}
+	totalCost := m.totalCost
+
+	for i, op := range ops {
+		if err := m.applyOp(op); err != nil {
+			m.data = data
+			m.order = order
+			m.rebuildIndex()
+			m.insertion = insertion
+			m.added = added
+			m.totalCost = totalCost
+			return fmt.Errorf("Operation %d: %s", i, err.Error())
+		}
+	}
+
+	m.evictOverCost()
+	return nil
+}
+
+// Apply a single change to the map.  The caller must hold the write lock.
+func (m *OrderedMap) applyOp(op Op) error {
+	switch op.Type {
+	case OpAdd:
+		if _, ok := m.data[op.Key]; !ok {
+			m.appendKey(op.Key)
+			m.recordInsertion(op.Key)
+		}
+		m.store(op.Key, op.Value)
+
+	case OpDelete:
+		if _, ok := m.data[op.Key]; !ok {
+			return fmt.Errorf("Key %q does not exist.", op.Key)
+		}
+		m.discard(op.Key)
+		m.removeFromOrder(op.Key)
+
+	case OpMove:
+		if _, ok := m.data[op.Key]; !ok {
+			return fmt.Errorf("Key %q does not exist.", op.Key)
+		}
+		if op.Position < 0 || op.Position >= len(m.order) {
+			return errors.New("Position is out of range.")
+		}
+		m.removeFromOrder(op.Key)
+		m.insertKeyAt(op.Position, op.Key)
+
+	case OpRename:
+		return m.rename(op.Key, op.NewKey)
+
+	default:
+		return fmt.Errorf("Unknown operation type %d.", op.Type)
+	}
+
+	return nil
+}
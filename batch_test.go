@@ -0,0 +1,70 @@
+package orderedmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyBatch(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	err := om.ApplyBatch([]Op{
+		{Type: OpAdd, Key: "four", Value: 4},
+		{Type: OpDelete, Key: "two"},
+		{Type: OpMove, Key: "four", Position: 0},
+		{Type: OpRename, Key: "one", NewKey: "uno"},
+		{Type: OpAdd, Key: "three", Value: 30},
+	})
+	if err != nil {
+		t.Error("Error applying batch: " + err.Error())
+	}
+
+	if got := strings.Join(om.GetOrder(), ","); got != "four,uno,three" {
+		t.Errorf("Order was wrong after batch: %s", got)
+	}
+	if val, _ := om.GetKey("uno"); val.(int) != 1 {
+		t.Error("Renamed key lost its value")
+	}
+	if val, _ := om.GetKey("three"); val.(int) != 30 {
+		t.Error("Existing key was not updated")
+	}
+	if om.Count() != 3 {
+		t.Error("Size of ordered map was wrong")
+	}
+}
+
+func TestApplyBatchRollback(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	err := om.ApplyBatch([]Op{
+		{Type: OpAdd, Key: "four", Value: 4},
+		{Type: OpDelete, Key: "one"},
+		{Type: OpRename, Key: "two", NewKey: "three"},
+		{Type: OpMove, Key: "four", Position: 0},
+	})
+	if err == nil {
+		t.Fatal("No error was received for an invalid batch.")
+	}
+	if !strings.HasPrefix(err.Error(), "Operation 2:") {
+		t.Errorf("Error did not identify the failed operation: %v", err)
+	}
+
+	if got := strings.Join(om.GetOrder(), ","); got != "one,two,three" {
+		t.Errorf("Order was changed by a failed batch: %s", got)
+	}
+	if om.Count() != 3 {
+		t.Error("Size of ordered map was changed by a failed batch")
+	}
+	if _, ok := om.GetKey("four"); ok {
+		t.Error("Added key survived a failed batch")
+	}
+	if _, ok := om.GetKey("one"); !ok {
+		t.Error("Deleted key was not restored after a failed batch")
+	}
+}
@@ -0,0 +1,138 @@
+package orderedmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// Encode the map as a JSON object, implementing json.Marshaler.  Keys appear in
+// the same order as the map, and values are encoded with the standard encoder.
+// An empty map is encoded as {}.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	return marshalTuples(m.tuples())
+}
+
+// Decode the map from a JSON object, implementing json.Unmarshaler.  Items are
+// added in the order their keys appear in the document, replacing anything
+// already in the map.  Values are decoded the same way the standard library
+// decodes into an interface{}, so numbers become float64 and nested objects
+// become map[string]interface{}.  An error is returned if the document is not
+// a JSON object.  It is safe to unmarshal into an OrderedMap that was not
+// created with New.
+func (m *OrderedMap) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return errors.New("JSON value is not an object.")
+	}
+
+	entries := make([]Tuple, 0)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return errors.New("JSON object key is not a string.")
+		}
+
+		var val interface{}
+		if err := dec.Decode(&val); err != nil {
+			return err
+		}
+		entries = append(entries, Tuple{key, val})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	m.load(entries)
+	return nil
+}
+
+// Get a JSON object holding every item in the map, with keys sorted lexically
+// rather than in the map's order.  Two maps with the same contents will always
+// produce exactly the same bytes, no matter how they are ordered, which makes
+// this suitable for hashing or signing.  Maps nested inside as values, directly
+// or inside []interface{} or map[string]interface{} values, are written with
+// sorted keys too.
+func (m *OrderedMap) MarshalJSONCanonical() ([]byte, error) {
+	entries := m.tuples()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	for i, t := range entries {
+		val, err := canonicalValue(t.Val)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Val = val
+	}
+	return marshalTuples(entries)
+}
+
+// Replace any OrderedMap in a value with its canonical JSON, looking inside
+// slices and maps of interface{} values as well.
+func canonicalValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case *OrderedMap:
+		if val == nil {
+			return nil, nil
+		}
+		b, err := val.MarshalJSONCanonical()
+		return json.RawMessage(b), err
+	case []interface{}:
+		tmp := make([]interface{}, len(val))
+		for i, item := range val {
+			c, err := canonicalValue(item)
+			if err != nil {
+				return nil, err
+			}
+			tmp[i] = c
+		}
+		return tmp, nil
+	case map[string]interface{}:
+		tmp := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			c, err := canonicalValue(item)
+			if err != nil {
+				return nil, err
+			}
+			tmp[k] = c
+		}
+		return tmp, nil
+	}
+	return v, nil
+}
+
+// Encode a slice of Tuples as a JSON object, keeping keys in the order given.
+func marshalTuples(entries []Tuple) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, t := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(t.Val)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package orderedmap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	om := New()
+	om.Add("zeta", 1)
+	om.Add("alpha", TestData{ID: 2, Name: "two"})
+	om.Add("mid", []string{"a", "b"})
+
+	out, err := json.Marshal(om)
+	if err != nil {
+		t.Fatal("Error marshalling map: " + err.Error())
+	}
+
+	expected := `{"zeta":1,"alpha":{"ID":2,"Name":"two"},"mid":["a","b"]}`
+	if string(out) != expected {
+		t.Errorf("JSON was wrong: %s", out)
+	}
+
+	empty := New()
+	out, err = json.Marshal(empty)
+	if err != nil {
+		t.Fatal("Error marshalling map: " + err.Error())
+	}
+	if string(out) != "{}" {
+		t.Errorf("Empty map produced %s", out)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	doc := `{"zeta":1,"alpha":{"ID":2},"mid":["a","b"],"last":null}`
+
+	om := New()
+	om.Add("old", "value")
+	if err := json.Unmarshal([]byte(doc), om); err != nil {
+		t.Fatal("Error unmarshalling map: " + err.Error())
+	}
+
+	if got := strings.Join(om.GetOrder(), ","); got != "zeta,alpha,mid,last" {
+		t.Errorf("Order did not match the document: %s", got)
+	}
+	if _, ok := om.GetKey("old"); ok {
+		t.Error("Existing contents were not replaced")
+	}
+	if val, _ := om.GetKey("zeta"); val.(float64) != 1 {
+		t.Errorf("Number was decoded as %T", val)
+	}
+	if val, _ := om.GetKey("alpha"); val.(map[string]interface{})["ID"].(float64) != 2 {
+		t.Error("Nested object was decoded incorrectly")
+	}
+
+	var zero OrderedMap
+	if err := json.Unmarshal([]byte(doc), &zero); err != nil {
+		t.Fatal("Error unmarshalling into a zero map: " + err.Error())
+	}
+	out, _ := json.Marshal(&zero)
+	if string(out) != `{"zeta":1,"alpha":{"ID":2},"mid":["a","b"],"last":null}` {
+		t.Errorf("Map did not round trip: %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`[1,2,3]`), om); err == nil {
+		t.Error("No error was received when unmarshalling an array.")
+	}
+}
+
+func TestMarshalJSONCanonical(t *testing.T) {
+	first := New()
+	first.Add("one", TestData{ID: 1, Name: "one"})
+	first.Add("two", 2)
+	first.Add("three", []string{"a", "b"})
+
+	second := New()
+	second.Add("three", []string{"a", "b"})
+	second.Add("one", TestData{ID: 1, Name: "one"})
+	second.Add("two", 2)
+
+	a, err := first.MarshalJSONCanonical()
+	if err != nil {
+		t.Error("Error marshalling map: " + err.Error())
+	}
+	b, err := second.MarshalJSONCanonical()
+	if err != nil {
+		t.Error("Error marshalling map: " + err.Error())
+	}
+
+	if string(a) != string(b) {
+		t.Errorf("Canonical JSON did not match: %s != %s", a, b)
+	}
+
+	expected := `{"one":{"ID":1,"Name":"one"},"three":["a","b"],"two":2}`
+	if string(a) != expected {
+		t.Errorf("Canonical JSON was wrong: %s", a)
+	}
+
+	empty := New()
+	if out, _ := empty.MarshalJSONCanonical(); string(out) != "{}" {
+		t.Errorf("Empty map produced %s", out)
+	}
+}
+
+func TestMarshalJSONCanonicalNested(t *testing.T) {
+	xy := New()
+	xy.Add("x", 1)
+	xy.Add("y", 2)
+	yx := New()
+	yx.Add("y", 2)
+	yx.Add("x", 1)
+
+	a := New()
+	a.Add("n", xy)
+	a.Add("list", []interface{}{xy})
+	a.Add("plain", map[string]interface{}{"m": xy})
+	b := New()
+	b.Add("plain", map[string]interface{}{"m": yx})
+	b.Add("list", []interface{}{yx})
+	b.Add("n", yx)
+
+	outA, err := a.MarshalJSONCanonical()
+	if err != nil {
+		t.Fatal("Error marshalling canonical JSON: " + err.Error())
+	}
+	outB, err := b.MarshalJSONCanonical()
+	if err != nil {
+		t.Fatal("Error marshalling canonical JSON: " + err.Error())
+	}
+	if string(outA) != string(outB) {
+		t.Errorf("Nested maps in different orders gave different canonical JSON: %s and %s", outA, outB)
+	}
+
+	expected := `{"list":[{"x":1,"y":2}],"n":{"x":1,"y":2},"plain":{"m":{"x":1,"y":2}}}`
+	if string(outB) != expected {
+		t.Errorf("Canonical JSON was wrong: %s", outB)
+	}
+}
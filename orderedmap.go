@@ -21,143 +21,1960 @@ using the GetKey and GetIndex functions:
 package orderedmap
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"iter"
+	"math/rand"
+	"net/url"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
-// A map structure that stores data within an ordered fashion.
+// A map structure that stores data within an ordered fashion.  Maps should be
+// made with New or one of the other constructors.  The zero value is an empty
+// map that can safely be read from or unmarshalled into, but adding items to it
+// any other way will panic.
 type OrderedMap struct {
-	data  map[string]interface{}
-	order []string
-	lock  sync.RWMutex
+	data      map[string]interface{}
+	order     []string
+	index     map[string]int
+	head      int
+	insertion []string
+	added     map[string]time.Time
+	now       func() time.Time
+	maxCost   int64
+	cost      func(interface{}) int64
+	totalCost int64
+	lock      mapLock
+}
+
+// The locking used to protect an OrderedMap.  This is a sync.RWMutex, unless
+// the map was created with NewUnsafe, in which case locking is turned off.  The
+// zero value is a working lock.
+type mapLock struct {
+	mu       sync.RWMutex
+	disabled bool
+}
+
+func (l *mapLock) Lock() {
+	if !l.disabled {
+		l.mu.Lock()
+	}
+}
+
+func (l *mapLock) Unlock() {
+	if !l.disabled {
+		l.mu.Unlock()
+	}
+}
+
+func (l *mapLock) RLock() {
+	if !l.disabled {
+		l.mu.RLock()
+	}
+}
+
+func (l *mapLock) RUnlock() {
+	if !l.disabled {
+		l.mu.RUnlock()
+	}
 }
 
 // Create a new ordered map object
-func New() OrderedMap {
-	return OrderedMap{
+func New() *OrderedMap {
+	return NewWithCapacity(0)
+}
+
+// Create a new ordered map object with room for n items already allocated.
+// This saves growing the map over and over when a large number of items is
+// about to be added.
+func NewWithCapacity(n int) *OrderedMap {
+	return &OrderedMap{
+		data:  make(map[string]interface{}, n),
+		order: make([]string, 0, n),
+		index: make(map[string]int, n),
+	}
+}
+
+// Create a new ordered map object that does no locking at all.  This avoids the
+// cost of the mutex in hot loops that only ever use the map from a single
+// goroutine.
+//
+// WARNING: A map created this way is NOT safe for concurrent use.  Using it from
+// more than one goroutine at a time, even if only one of them writes, will
+// corrupt the map or crash your program.
+func NewUnsafe() *OrderedMap {
+	return &OrderedMap{
+		data:  make(map[string]interface{}),
+		order: make([]string, 0),
+		index: make(map[string]int),
+		lock:  mapLock{disabled: true},
+	}
+}
+
+// Create a new ordered map object that also remembers the order in which keys
+// were originally added.  The normal order of the map becomes a display order
+// that can be changed freely with SetOrder and friends, while InsertionOrder
+// will always return keys in the sequence they were added.
+func NewWithDisplayOrder() *OrderedMap {
+	return &OrderedMap{
+		data:      make(map[string]interface{}),
+		order:     make([]string, 0),
+		index:     make(map[string]int),
+		insertion: make([]string, 0),
+	}
+}
+
+// Create a new ordered map object that records the time at which each key was
+// added, which can be retrieved with AddedAt.  A key's time is recorded when it
+// is first added to the map, and whenever it is passed to Add, which always
+// treats the item as newly added.  Set and other ways of updating the value of
+// an existing key keep its original time.
+func NewTimestamped() *OrderedMap {
+	return &OrderedMap{
 		data:  make(map[string]interface{}),
 		order: make([]string, 0),
+		index: make(map[string]int),
+		added: make(map[string]time.Time),
+		now:   time.Now,
+	}
+}
+
+// Create a new ordered map object that limits the total cost of the values it
+// holds, where the cost of each value is decided by the provided function.
+// Whenever adding or changing a value brings the total cost over maxCost, items
+// are removed from the front of the map until it is back within budget.  This
+// can be used to build caches that are limited by size in bytes rather than by
+// a number of items.
+func NewSizeBounded(maxCost int64, cost func(interface{}) int64) *OrderedMap {
+	return &OrderedMap{
+		data:    make(map[string]interface{}),
+		order:   make([]string, 0),
+		index:   make(map[string]int),
+		maxCost: maxCost,
+		cost:    cost,
+	}
+}
+
+// Create a new ordered map by pairing each key with the value at the same
+// index, in order.  An error is returned if the slices are different lengths or
+// if a key is repeated.
+func FromSlices(keys []string, values []interface{}) (*OrderedMap, error) {
+	if len(keys) != len(values) {
+		return nil, errors.New("Keys and values are not the same length.")
+	}
+
+	m := New()
+	for i, k := range keys {
+		if _, ok := m.data[k]; ok {
+			return nil, fmt.Errorf("Key %q is duplicated.", k)
+		}
+		m.data[k] = values[i]
+		m.appendKey(k)
+	}
+
+	return m, nil
+}
+
+// Create a new ordered map holding every item in a standard map, ordered by key.
+// Since the keys are sorted, the same standard map always gives the same order.
+func FromMap(data map[string]interface{}) *OrderedMap {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := NewWithCapacity(len(keys))
+	for _, k := range keys {
+		m.data[k] = data[k]
+		m.appendKey(k)
+	}
+	return m
+}
+
+// Create a new ordered map counting how many times each item appears in the
+// provided slice.  Each distinct item becomes a key, in the order it was first
+// seen, with an int value holding its count.
+func CountOf(items []string) *OrderedMap {
+	m := New()
+	for _, item := range items {
+		if cnt, ok := m.data[item]; ok {
+			m.data[item] = cnt.(int) + 1
+		} else {
+			m.data[item] = 1
+			m.appendKey(item)
+		}
+	}
+	return m
+}
+
+// Set the value of a key in the map.  If the key already exists, its value is
+// updated and it keeps its current position; otherwise it is added onto the end
+// of the map.  Unlike Add, this never leaves the same key in the order twice.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	m.lock.Lock()
+	if _, ok := m.data[key]; !ok {
+		m.appendKey(key)
+		m.recordInsertion(key)
 	}
+	m.store(key, value)
+	m.evictOverCost()
+	m.lock.Unlock()
 }
 
 // Add an object onto the end of the map
 func (m *OrderedMap) Add(key string, value interface{}) {
 	m.lock.Lock()
-	m.data[key] = value
-	m.order = append(m.order, key)
+	m.store(key, value)
+	m.appendKey(key)
+	m.recordInsertion(key)
+	m.evictOverCost()
+	m.lock.Unlock()
+}
+
+// Add an object onto the end of the map, or update it in place if the key
+// already exists, but only if validate returns nil for the value.  Otherwise,
+// the error from validate is returned and the map is left unchanged.
+func (m *OrderedMap) AddValidated(key string, value interface{}, validate func(interface{}) error) error {
+	if err := validate(value); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	if _, ok := m.data[key]; !ok {
+		m.appendKey(key)
+		m.recordInsertion(key)
+	}
+	m.store(key, value)
+	m.evictOverCost()
+	m.lock.Unlock()
+	return nil
+}
+
+// Add an object onto the end of the map, or if the key already exists, update
+// its value and move it to the end.  This keeps the most recently used key at
+// the back of the map.
+func (m *OrderedMap) AddOrMoveToBack(key string, value interface{}) {
+	m.lock.Lock()
+	if _, ok := m.data[key]; ok {
+		m.removeFromOrder(key)
+	} else {
+		m.recordInsertion(key)
+	}
+	m.store(key, value)
+	m.appendKey(key)
+	m.evictOverCost()
 	m.lock.Unlock()
 }
 
+// Add an object onto the end of the map, or update it in place if the key
+// already exists, and then remove the first item in the map if it now holds
+// more than max items.  This gives simple fixed size window semantics.  If an
+// item was removed, its key is returned along with true.
+func (m *OrderedMap) AddBounded(key string, value interface{}, max int) (evictedKey string, evicted bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.data[key]; !ok {
+		m.appendKey(key)
+		m.recordInsertion(key)
+	}
+	m.store(key, value)
+	m.evictOverCost()
+
+	if len(m.order) > max {
+		return m.removeIndex(0), true
+	}
+	return "", false
+}
+
 // Add an object to a specific position in the map.  Position is zero indexed,
 // so to add to the very beginning, you would use 0, to add to the end you would
-// use Count() - 1.
+// use Count().  If the key already exists, its value is updated and it is moved
+// so that it ends up at position, rather than being added twice; in that case
+// the end of the map is Count() - 1.
 func (m *OrderedMap) Insert(position int, key string, value interface{}) error {
-	if position >= len(m.data) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, exists := m.data[key]
+	size := len(m.order)
+	if exists {
+		// The key's old position is removed before it is inserted again.
+		size--
+	}
+
+	if position > size {
+		return errors.New("Position is larger than the current map size.")
+	}
+
+	if position < 0 {
+		return errors.New("Position is less than 0.")
+	}
+
+	if exists {
+		m.removeFromOrder(key)
+	} else {
+		m.recordInsertion(key)
+	}
+	m.store(key, value)
+	m.insertKeyAt(position, key)
+	m.evictOverCost()
+
+	return nil
+}
+
+// Add an object to the map directly before an existing anchor key.  If the key
+// already exists, its value is updated and it is moved next to the anchor,
+// rather than being added twice.  An error is returned if the anchor does not
+// exist.
+func (m *OrderedMap) InsertBefore(anchor, key string, value interface{}) error {
+	return m.insertNextTo(anchor, key, value, 0)
+}
+
+// Add an object to the map directly after an existing anchor key.  If the key
+// already exists, its value is updated and it is moved next to the anchor,
+// rather than being added twice.  An error is returned if the anchor does not
+// exist.
+func (m *OrderedMap) InsertAfter(anchor, key string, value interface{}) error {
+	return m.insertNextTo(anchor, key, value, 1)
+}
+
+// Add an object to the map at the position that keeps it sorted according to
+// less, which should return true when item a belongs before item b.  The map
+// must already be sorted the same way.  New items go after any items they
+// compare equal to.  If the key already exists, its value is updated and it is
+// moved to wherever the new value belongs, rather than being added twice.  The
+// less function is called while the map is locked, so it must not call back
+// into this map.
+func (m *OrderedMap) InsertSortedFunc(key string, value interface{}, less func(aKey string, aVal interface{}, bKey string, bVal interface{}) bool) {
+	m.lock.Lock()
+	if _, ok := m.data[key]; ok {
+		m.removeFromOrder(key)
+	} else {
+		m.recordInsertion(key)
+	}
+	m.store(key, value)
+
+	idx := sort.Search(len(m.order), func(i int) bool {
+		k := m.order[i]
+		return less(key, value, k, m.data[k])
+	})
+	m.insertKeyAt(idx, key)
+	m.evictOverCost()
+	m.lock.Unlock()
+}
+
+// Add several new objects to the map, in order, starting at a specific
+// position.  A position of Count() will add them onto the end.  An error is
+// returned, and the map is left unchanged, if the position is out of range or
+// if any key is repeated in entries or already exists in the map.
+func (m *OrderedMap) InsertAll(position int, entries []Tuple) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if position > len(m.order) {
 		return errors.New("Position is larger than the current map size.")
 	}
 
-	if position < 0 {
-		return errors.New("Position is less than 0.")
+	if position < 0 {
+		return errors.New("Position is less than 0.")
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, t := range entries {
+		if _, ok := m.data[t.Key]; ok || seen[t.Key] {
+			return fmt.Errorf("Key %q is duplicated.", t.Key)
+		}
+		seen[t.Key] = true
+	}
+
+	tmp := make([]string, 0, len(m.order)+len(entries))
+	tmp = append(tmp, m.order[:position]...)
+	for _, t := range entries {
+		m.store(t.Key, t.Val)
+		tmp = append(tmp, t.Key)
+		m.recordInsertion(t.Key)
+	}
+	m.order = append(tmp, m.order[position:]...)
+	m.reindex(position)
+	m.evictOverCost()
+
+	return nil
+}
+
+// Add a Tuple onto the end of the map.  This allows data returned from an
+// iterator to be added back into a map without pulling it apart first.
+func (m *OrderedMap) AddTuple(t Tuple) {
+	m.Add(t.Key, t.Val)
+}
+
+// Add a Tuple to a specific position in the map, following the same rules as
+// Insert.
+func (m *OrderedMap) InsertTuple(position int, t Tuple) error {
+	return m.Insert(position, t.Key, t.Val)
+}
+
+// Get a specific object out of the map based on its map key.  In the event the
+// key does not exist or the data is out of range, the function will have a
+// second return of false.
+//
+// Get key:
+// 	data, ok := om.GetKey("mykey")
+//
+// Test if key exists:
+// 	if _, ok := om.GetKey("mykey"); ok {
+// 		... DO SOMETHING HERE ...
+// 	}
+func (m *OrderedMap) GetKey(key string) (interface{}, bool) {
+	m.lock.RLock()
+	data, ok := m.data[key]
+	m.lock.RUnlock()
+	return data, ok
+}
+
+// Get a specific object out of the map based on its map key, or def if the key
+// does not exist.
+func (m *OrderedMap) GetOrDefault(key string, def interface{}) interface{} {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if data, ok := m.data[key]; ok {
+		return data
+	}
+	return def
+}
+
+// Get a copy of a specific object out of the map based on its map key, made by
+// calling copyFn on the stored value.  This lets callers hand out values such
+// as slices or pointers without sharing the map's copy.  If copyFn is nil, the
+// stored value is returned as is.  In the event the key does not exist, the
+// function will have a second return of false and copyFn is not called.
+func (m *OrderedMap) GetCopy(key string, copyFn func(interface{}) interface{}) (interface{}, bool) {
+	m.lock.RLock()
+	data, ok := m.data[key]
+	m.lock.RUnlock()
+	if !ok || copyFn == nil {
+		return data, ok
+	}
+	return copyFn(data), true
+}
+
+// Get a specific object out of the map based on its map key, along with its
+// order index.  This is done in one step, so the index is guaranteed to match
+// the returned data.  In the event the key does not exist, the index will be -1
+// and the third return will be false.
+func (m *OrderedMap) GetKeyWithIndex(key string) (interface{}, int, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	data, ok := m.data[key]
+	if !ok {
+		return nil, -1, false
+	}
+	index, _ := m.position(key)
+	return data, index, true
+}
+
+// Get a specific object out of the map based on its map key, or if the key does
+// not exist, call compute and add its result onto the end of the map.  The
+// compute function is called while the map is locked, so concurrent callers
+// will never compute the same key twice, but it must not call back into this
+// map.
+func (m *OrderedMap) GetOrCompute(key string, compute func() interface{}) interface{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if data, ok := m.data[key]; ok {
+		return data
+	}
+	data := compute()
+	m.store(key, data)
+	m.appendKey(key)
+	m.recordInsertion(key)
+	m.evictOverCost()
+	return data
+}
+
+// Get a specific object out of the map based on its map key, or if the key does
+// not exist, add value onto the end of the map and get it back instead.  This
+// is the same as sync.Map's LoadOrStore, and loaded will be true if the value
+// was already in the map.
+func (m *OrderedMap) GetOrSet(key string, value interface{}) (actual interface{}, loaded bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if data, ok := m.data[key]; ok {
+		return data, true
+	}
+	m.store(key, value)
+	m.appendKey(key)
+	m.recordInsertion(key)
+	m.evictOverCost()
+	return value, false
+}
+
+// Test if a key exists in the map.
+//
+// 	if om.Has("mykey") {
+// 		... DO SOMETHING HERE ...
+// 	}
+func (m *OrderedMap) Has(key string) bool {
+	m.lock.RLock()
+	_, ok := m.data[key]
+	m.lock.RUnlock()
+	return ok
+}
+
+// Test if every one of the provided keys exists in the map.  An empty slice of
+// keys will always return true.
+func (m *OrderedMap) HasAll(keys []string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, k := range keys {
+		if _, ok := m.data[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Test if at least one of the provided keys exists in the map.  An empty slice
+// of keys will always return false.
+func (m *OrderedMap) HasAny(keys []string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, k := range keys {
+		if _, ok := m.data[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Get a specific object and it's key out of the map based on it's order index,
+// with 0 being the first item in the order.  Will return a false in the event
+// The key does not exist or the index is out of range.
+func (m *OrderedMap) GetIndex(index int) (string, interface{}, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if index < 0 || index >= len(m.order) {
+		return "", nil, false
+	}
+	key := m.order[index]
+	data, ok := m.data[key]
+	return key, data, ok
+}
+
+// Get a specific object and it's key out of the map based on a fraction of the
+// way through its order, where 0 is the first item and 1 is the last.  The
+// index used is rounded down, so 0.5 gives the lower middle item of a map with
+// an even number of items.  Will return false if the map is empty or f is not
+// between 0 and 1.
+func (m *OrderedMap) ValueAtFraction(f float64) (string, interface{}, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if len(m.order) == 0 || !(f >= 0 && f <= 1) {
+		return "", nil, false
+	}
+	key := m.order[int(f*float64(len(m.order)-1))]
+	return key, m.data[key], true
+}
+
+// Get a slice of strings containing the current order of the array
+func (m *OrderedMap) GetOrder() []string {
+	m.lock.RLock()
+	tmp := make([]string, len(m.order))
+	copy(tmp, m.order)
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Get a slice containing the values of the map, in their current order.
+func (m *OrderedMap) Values() []interface{} {
+	m.lock.RLock()
+	tmp := make([]interface{}, len(m.order))
+	for i, k := range m.order {
+		tmp[i] = m.data[k]
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Get the values stored in the map, in order, for editing in place.  These are
+// the very same interface values held by the map, so if a value is a pointer,
+// changes made through it are seen by the map as well.
+//
+// NOTE: If a value is not a pointer, such as a plain struct, the returned value
+// is only a copy and changes made to it will NOT be reflected in the map.
+func (m *OrderedMap) ValuePtrs() []interface{} {
+	return m.Values()
+}
+
+// Set a new order for this map.  SetOrder will return an error if either the
+// number of items in the provided slice is different than those in the map, or
+// if the keys are different that those currently in use.
+func (m *OrderedMap) SetOrder(order []string) error {
+	if !compareOrder(m.order, order) {
+		return errors.New("Provided order does not contain the same data as existing.")
+	}
+	m.lock.Lock()
+	copy(m.order, order)
+	m.rebuildIndex()
+	m.lock.Unlock()
+	return nil
+}
+
+// Reorder the map to follow a reference order as closely as possible.  Keys
+// found in reference are moved to the front in that order, and any remaining
+// keys follow in their current relative order.  Keys in reference that do not
+// exist in the map are ignored.  This is a lenient version of SetOrder.
+func (m *OrderedMap) ReorderByReference(reference []string) {
+	m.lock.Lock()
+	placed := make(map[string]bool, len(reference))
+	tmp := make([]string, 0, len(m.order))
+	for _, k := range reference {
+		if _, ok := m.data[k]; ok && !placed[k] {
+			tmp = append(tmp, k)
+			placed[k] = true
+		}
+	}
+	for _, k := range m.order {
+		if !placed[k] {
+			tmp = append(tmp, k)
+		}
+	}
+	m.order = tmp
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map in place using the provided comparison function, which
+// should return true when the item a belongs before the item b.  Items that
+// compare as equal keep their current relative order.  Unlike SetOrder, there
+// is no need to build and validate a complete new order.  The less function is
+// called while the map is locked, so it must not call back into this map.
+func (m *OrderedMap) OrderBy(less func(aKey string, aVal interface{}, bKey string, bVal interface{}) bool) {
+	m.lock.Lock()
+	sort.SliceStable(m.order, func(i, j int) bool {
+		a, b := m.order[i], m.order[j]
+		return less(a, m.data[a], b, m.data[b])
+	})
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map so that its keys are in ascending order.  Values are left
+// unchanged.
+func (m *OrderedMap) SortKeys() {
+	m.lock.Lock()
+	sort.Strings(m.order)
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map using the provided comparison function, which should return
+// true when the item a belongs before the item b.  Items that compare as equal
+// keep their current relative order.  This is the same as OrderBy, but with
+// each item passed as a Tuple.  The less function is called while the map is
+// locked, so it must not call back into this map.
+func (m *OrderedMap) SortFunc(less func(a, b Tuple) bool) {
+	m.lock.Lock()
+	sort.SliceStable(m.order, func(i, j int) bool {
+		a, b := m.order[i], m.order[j]
+		return less(Tuple{a, m.data[a]}, Tuple{b, m.data[b]})
+	})
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map by key using the provided comparison function, which should
+// return true when key a belongs before key b.  Keys that compare as equal keep
+// their current relative order, and values are left unchanged.  The less
+// function is called while the map is locked, so it must not call back into
+// this map.
+func (m *OrderedMap) SortByKeyFunc(less func(a, b string) bool) {
+	m.lock.Lock()
+	sort.SliceStable(m.order, func(i, j int) bool {
+		return less(m.order[i], m.order[j])
+	})
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reverse the order of the items from index start up to, but not including,
+// index end.  Items outside of the range and all values are left unchanged.
+func (m *OrderedMap) ReverseRange(start, end int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if start < 0 {
+		return errors.New("Start is less than 0.")
+	}
+	if end > len(m.order) {
+		return errors.New("End is larger than the current map size.")
+	}
+	if start > end {
+		return errors.New("Start is larger than end.")
+	}
+
+	for i, j := start, end-1; i < j; i, j = i+1, j-1 {
+		m.order[i], m.order[j] = m.order[j], m.order[i]
+	}
+	m.reindex(start)
+	return nil
+}
+
+// Move an existing key to the front of the map.  Values are left unchanged.  An
+// error is returned if the key does not exist.
+func (m *OrderedMap) MoveToFront(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.data[key]; !ok {
+		return fmt.Errorf("Key %q does not exist.", key)
+	}
+	m.removeFromOrder(key)
+	m.insertKeyAt(0, key)
+	return nil
+}
+
+// Move an existing key to the back of the map.  Values are left unchanged.  An
+// error is returned if the key does not exist.
+func (m *OrderedMap) MoveToBack(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.data[key]; !ok {
+		return fmt.Errorf("Key %q does not exist.", key)
+	}
+	m.removeFromOrder(key)
+	m.appendKey(key)
+	return nil
+}
+
+// Move an existing key so that it ends up at a specific position in the map,
+// with the items in between shifting over to make room.  Position is zero
+// indexed, so to move to the end you would use Count() - 1.  Values are left
+// unchanged.  An error is returned if the key does not exist or the position
+// is out of range.
+func (m *OrderedMap) Move(key string, position int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.data[key]; !ok {
+		return fmt.Errorf("Key %q does not exist.", key)
+	}
+
+	if position >= len(m.order) {
+		return errors.New("Position is larger than the current map size.")
+	}
+
+	if position < 0 {
+		return errors.New("Position is less than 0.")
+	}
+
+	m.removeFromOrder(key)
+	m.insertKeyAt(position, key)
+	return nil
+}
+
+// Reverse the order of every item in the map, so that the first item becomes
+// the last.  Values are left unchanged.
+func (m *OrderedMap) Reverse() {
+	m.lock.Lock()
+	for i, j := 0, len(m.order)-1; i < j; i, j = i+1, j-1 {
+		m.order[i], m.order[j] = m.order[j], m.order[i]
+	}
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map so that every item satisfying pred comes first, followed by
+// every other item, with both groups keeping their current relative order.
+// Values are left unchanged.  The pred function is called while the map is
+// locked, so it must not call back into this map.
+func (m *OrderedMap) StablePartition(pred func(key string, value interface{}) bool) {
+	m.lock.Lock()
+	matched, rest := m.partition(pred)
+	m.order = append(matched, rest...)
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map so that every item satisfying pred comes last, after every
+// other item, with both groups keeping their current relative order.  Values
+// are left unchanged.  The pred function is called while the map is locked, so
+// it must not call back into this map.
+func (m *OrderedMap) MoveMatchingToBack(pred func(key string, value interface{}) bool) {
+	m.lock.Lock()
+	matched, rest := m.partition(pred)
+	m.order = append(rest, matched...)
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Shuffle the order of the map using a random source created from seed.  The
+// same seed will always give the same order for a map with the same contents
+// and order, and the global random source is not touched.
+func (m *OrderedMap) ShuffleSeeded(seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	m.lock.Lock()
+	r.Shuffle(len(m.order), func(i, j int) {
+		m.order[i], m.order[j] = m.order[j], m.order[i]
+	})
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Reorder the map by the value of a named field on each item's value, which must
+// be a struct or a pointer to one.  The field may be any integer, float, or
+// string type, but must be the same type for every value.  An error is
+// returned, and the order is left unchanged, if any value is not a struct,
+// lacks the field, or has a field that cannot be sorted.
+func (m *OrderedMap) ReindexByValueField(field string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var kind reflect.Kind
+	fields := make(map[string]reflect.Value, len(m.order))
+	for i, k := range m.order {
+		v := reflect.ValueOf(m.data[k])
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("Value for key %q is not a struct.", k)
+		}
+		sf, ok := v.Type().FieldByName(field)
+		if !ok {
+			return fmt.Errorf("Value for key %q has no field %q.", k, field)
+		}
+		f, err := v.FieldByIndexErr(sf.Index)
+		if err != nil {
+			return fmt.Errorf("Field %q for key %q cannot be reached through a nil pointer.", field, k)
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64, reflect.String:
+		default:
+			return fmt.Errorf("Field %q for key %q cannot be sorted.", field, k)
+		}
+		if i == 0 {
+			kind = f.Kind()
+		} else if f.Kind() != kind {
+			return fmt.Errorf("Field %q for key %q is not the same type as the others.", field, k)
+		}
+		fields[k] = f
+	}
+
+	sort.SliceStable(m.order, func(i, j int) bool {
+		a, b := fields[m.order[i]], fields[m.order[j]]
+		switch kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		default:
+			return a.Uint() < b.Uint()
+		}
+	})
+	m.rebuildIndex()
+	return nil
+}
+
+// Get a copy of every item in the map as a slice of Tuples, sorted using the
+// provided comparison function.  The map's own order is left unchanged.
+func (m *OrderedMap) SortedTuples(less func(a, b Tuple) bool) []Tuple {
+	tmp := m.tuples()
+	sort.SliceStable(tmp, func(i, j int) bool {
+		return less(tmp[i], tmp[j])
+	})
+	return tmp
+}
+
+// Get a slice of strings containing the order in which keys were originally
+// added to the map, regardless of any later reordering.  This is only tracked
+// for maps created with NewWithDisplayOrder, and will return nil otherwise.
+func (m *OrderedMap) InsertionOrder() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if m.insertion == nil {
+		return nil
+	}
+	tmp := make([]string, len(m.insertion))
+	copy(tmp, m.insertion)
+	return tmp
+}
+
+// Get the time at which a key was added to the map.  This is only tracked for
+// maps created with NewTimestamped, and the second return will be false if the
+// map is not tracking times or the key does not exist.
+func (m *OrderedMap) AddedAt(key string) (time.Time, bool) {
+	m.lock.RLock()
+	t, ok := m.added[key]
+	m.lock.RUnlock()
+	return t, ok
+}
+
+// Get the order index of a specific key, or -1 if it does not exist.  If the
+// key appears in the order more than once, its first index is returned.
+func (m *OrderedMap) IndexOf(key string) int {
+	m.lock.RLock()
+	index, ok := m.position(key)
+	m.lock.RUnlock()
+	if !ok {
+		return -1
+	}
+	return index
+}
+
+// Get a map of every key to its current order index.  The returned map is a
+// copy, so it will not change if the map is reordered later.
+func (m *OrderedMap) IndexMap() map[string]int {
+	m.lock.RLock()
+	tmp := make(map[string]int, len(m.index))
+	for k, i := range m.index {
+		tmp[k] = i - m.head
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Get the order index at which key would need to be inserted to keep the map
+// sorted by key.  The map's order must already be sorted by key, otherwise the
+// result is meaningless.  If the key already exists, its index is returned.
+func (m *OrderedMap) SearchSortedIndex(key string) int {
+	m.lock.RLock()
+	idx := sort.SearchStrings(m.order, key)
+	m.lock.RUnlock()
+	return idx
+}
+
+// Get the smallest key in the map that is greater than or equal to key.  The
+// map's order must already be sorted by key, otherwise the result is
+// meaningless.  Returns false if there is no such key.
+func (m *OrderedMap) CeilingKey(key string) (string, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	idx := sort.SearchStrings(m.order, key)
+	if idx < len(m.order) {
+		return m.order[idx], true
+	}
+	return "", false
+}
+
+// Get the largest key in the map that is less than or equal to key.  The map's
+// order must already be sorted by key, otherwise the result is meaningless.
+// Returns false if there is no such key.
+func (m *OrderedMap) FloorKey(key string) (string, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	idx := sort.SearchStrings(m.order, key)
+	if idx < len(m.order) && m.order[idx] == key {
+		return key, true
+	}
+	if idx > 0 {
+		return m.order[idx-1], true
+	}
+	return "", false
+}
+
+// Delete a specific key and all associated data from the map.  Deleting a key
+// that does not exist does nothing.  Deleting near either end of the map is
+// quick, while deleting from the middle of a large map takes time in proportion
+// to its size.
+func (m *OrderedMap) Delete(key string) {
+	m.lock.Lock()
+	if idx, ok := m.position(key); ok {
+		m.removeIndex(idx)
+	}
+	m.lock.Unlock()
+}
+
+// Delete the object at a specific position in the map, along with all
+// associated data.  Position is zero indexed, and the items after it move
+// forward by one.
+func (m *OrderedMap) DeleteIndex(position int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if position >= len(m.order) {
+		return errors.New("Position is larger than the current map size.")
+	}
+
+	if position < 0 {
+		return errors.New("Position is less than 0.")
+	}
+
+	m.removeIndex(position)
+	return nil
+}
+
+// Remove the first item from the map and get it back.  Returns false if the
+// map is empty.
+func (m *OrderedMap) PopFront() (string, interface{}, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.order) == 0 {
+		return "", nil, false
+	}
+	val := m.data[m.order[0]]
+	return m.removeIndex(0), val, true
+}
+
+// Remove the last item from the map and get it back.  Returns false if the map
+// is empty.
+func (m *OrderedMap) PopBack() (string, interface{}, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.order) == 0 {
+		return "", nil, false
+	}
+	val := m.data[m.order[len(m.order)-1]]
+	return m.removeIndex(len(m.order) - 1), val, true
+}
+
+// Remove items from the front of the map while pred returns true for them, and
+// then from the back of the map in the same way.  Items in between are kept
+// even if pred would return true for them.  The pred function is called while
+// the map is locked, so it must not call back into this map.
+func (m *OrderedMap) TrimFunc(pred func(key string, value interface{}) bool) {
+	m.lock.Lock()
+	start := 0
+	for start < len(m.order) && pred(m.order[start], m.data[m.order[start]]) {
+		start++
+	}
+	end := len(m.order)
+	for end > start && pred(m.order[end-1], m.data[m.order[end-1]]) {
+		end--
+	}
+
+	for _, k := range m.order[:start] {
+		m.discard(k)
+	}
+	for _, k := range m.order[end:] {
+		m.discard(k)
+	}
+
+	tmp := make([]string, end-start)
+	copy(tmp, m.order[start:end])
+	m.order = tmp
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Remove every item that was added longer ago than d, returning the number of
+// items removed.  The remaining items keep their order.  This only works on
+// maps created with NewTimestamped; on any other map it does nothing and
+// returns 0.
+func (m *OrderedMap) EvictOlderThan(d time.Duration) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.added == nil {
+		return 0
+	}
+
+	cutoff := m.now().Add(-d)
+	tmp := make([]string, 0, len(m.order))
+	for _, k := range m.order {
+		if m.added[k].Before(cutoff) {
+			m.discard(k)
+		} else {
+			tmp = append(tmp, k)
+		}
+	}
+	cnt := len(m.order) - len(tmp)
+	m.order = tmp
+	m.rebuildIndex()
+	return cnt
+}
+
+// Remove the items from index start up to, but not including, index end, and
+// get them back in order.  The remaining items keep their relative order.
+func (m *OrderedMap) Cut(start, end int) ([]Tuple, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if start < 0 {
+		return nil, errors.New("Start is less than 0.")
+	}
+	if end > len(m.order) {
+		return nil, errors.New("End is larger than the current map size.")
+	}
+	if start > end {
+		return nil, errors.New("Start is larger than end.")
+	}
+
+	tmp := make([]Tuple, 0, end-start)
+	for _, k := range m.order[start:end] {
+		tmp = append(tmp, Tuple{k, m.data[k]})
+		m.discard(k)
+	}
+	m.order = append(m.order[:start], m.order[end:]...)
+	m.rebuildIndex()
+	return tmp, nil
+}
+
+// Rebuild the map's internal storage so that it only holds the items that are
+// still in use.  Go maps never shrink, so after deleting a large number of items
+// the old storage can keep a lot of memory alive; calling GC releases it.  Any
+// key left in the order without data, or in it more than once, which Add can
+// cause, is dropped from the order as well.
+func (m *OrderedMap) GC() {
+	m.lock.Lock()
+	tmp := make(map[string]interface{}, len(m.data))
+	order := make([]string, 0, len(m.data))
+	for _, k := range m.order {
+		val, ok := m.data[k]
+		if !ok {
+			continue
+		}
+		if _, done := tmp[k]; done {
+			continue
+		}
+		tmp[k] = val
+		order = append(order, k)
+	}
+	m.data = tmp
+	m.order = order
+	m.rebuildIndex()
+	m.lock.Unlock()
+}
+
+// Get the total size of the map
+func (m *OrderedMap) Count() int {
+	m.lock.RLock()
+	cnt := len(m.data)
+	m.lock.RUnlock()
+	return cnt
+}
+
+// Get a readable description of the map and its items, in order, such as
+// OrderedMap{one:1, two:2}.  This is what fmt uses when printing a map.
+func (m *OrderedMap) String() string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("OrderedMap{")
+	for i, k := range m.order {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s:%v", k, m.data[k])
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Call fn for each item in order, stopping early if fn returns false.  This
+// avoids the goroutine and channels used by Iterator.  The items are copied
+// before fn is first called, so fn is free to use this map, including changing
+// it.
+func (m *OrderedMap) ForEach(fn func(key string, value interface{}) bool) {
+	for _, t := range m.tuples() {
+		if !fn(t.Key, t.Val) {
+			return
+		}
+	}
+}
+
+// Call fn for each item whose key starts with prefix, in order, stopping early
+// if fn returns false.  The matching items are gathered before fn is first
+// called, so fn is free to use this map.
+func (m *OrderedMap) RangePrefix(prefix string, fn func(key string, value interface{}) bool) {
+	m.lock.RLock()
+	tmp := make([]Tuple, 0)
+	for _, k := range m.order {
+		if strings.HasPrefix(k, prefix) {
+			tmp = append(tmp, Tuple{k, m.data[k]})
+		}
+	}
+	m.lock.RUnlock()
+
+	for _, t := range tmp {
+		if !fn(t.Key, t.Val) {
+			return
+		}
+	}
+}
+
+// Call fn for each item in order, along with the item that follows it, stopping
+// early if fn returns false.  For the last item, next will be empty and hasNext
+// will be false.  The items are copied before fn is first called, so fn is free
+// to use this map.
+func (m *OrderedMap) RangePairs(fn func(cur, next Tuple, hasNext bool) bool) {
+	entries := m.tuples()
+	for i, t := range entries {
+		var next Tuple
+		hasNext := i+1 < len(entries)
+		if hasNext {
+			next = entries[i+1]
+		}
+		if !fn(t, next, hasNext) {
+			return
+		}
+	}
+}
+
+// Call fn with the items of the map, in order, grouped into slices of at most
+// size items each.  If fn returns an error, no more batches are sent and that
+// error is returned.  The items are copied before fn is first called, so fn is
+// free to use this map.
+func (m *OrderedMap) EachBatch(size int, fn func(batch []Tuple) error) error {
+	if size < 1 {
+		return errors.New("Batch size is less than 1.")
+	}
+
+	entries := m.tuples()
+	for start := 0; start < len(entries); start += size {
+		end := start + size
+		if end > len(entries) {
+			end = len(entries)
+		}
+		if err := fn(entries[start:end:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fold over every item in order, starting from initial, and get the value of
+// the accumulator after each item.  The result has one entry per item, so the
+// last entry is the final result of the fold.  The items are copied before fn
+// is first called, so fn is free to use this map.
+func (m *OrderedMap) Scan(initial interface{}, fn func(acc interface{}, key string, value interface{}) interface{}) []interface{} {
+	entries := m.tuples()
+	tmp := make([]interface{}, len(entries))
+	acc := initial
+	for i, t := range entries {
+		acc = fn(acc, t.Key, t.Val)
+		tmp[i] = acc
+	}
+	return tmp
+}
+
+// Find the longest run of consecutive items that satisfy pred, returning the
+// index of its first item and its length.  If several runs are equally long,
+// the first one is returned.  If no items match, the result is -1 and 0.  The
+// items are copied before pred is first called, so pred is free to use this
+// map.
+func (m *OrderedMap) LongestRun(pred func(key string, value interface{}) bool) (startIndex, length int) {
+	entries := m.tuples()
+	startIndex = -1
+	run := 0
+	for i, t := range entries {
+		if !pred(t.Key, t.Val) {
+			run = 0
+			continue
+		}
+		run++
+		if run > length {
+			startIndex = i - run + 1
+			length = run
+		}
+	}
+	return startIndex, length
+}
+
+// Get all items whose keys fall between lo and hi, in order.  Keys equal to lo
+// are always included, while keys equal to hi are only included when inclusive
+// is true.  This is intended for maps whose order is sorted by key; on an
+// unsorted map the result is simply every item, in its current position, whose
+// key compares within the bounds.
+func (m *OrderedMap) RangeKeys(lo, hi string, inclusive bool) []Tuple {
+	m.lock.RLock()
+	tmp := make([]Tuple, 0)
+	for _, k := range m.order {
+		if k < lo || k > hi || (k == hi && !inclusive) {
+			continue
+		}
+		tmp = append(tmp, Tuple{k, m.data[k]})
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Create an independent copy of this map, with the same items in the same
+// order, that can be changed without affecting this map.  The copy keeps the
+// same kind of map, so a clone of a timestamped or size bounded map is also
+// timestamped or size bounded.  Values are copied as they are, so if a value is
+// a pointer, both maps will point at the same thing.
+func (m *OrderedMap) Clone() *OrderedMap {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	c := &OrderedMap{
+		data:      make(map[string]interface{}, len(m.data)),
+		order:     make([]string, len(m.order)),
+		index:     make(map[string]int, len(m.index)),
+		head:      m.head,
+		now:       m.now,
+		maxCost:   m.maxCost,
+		cost:      m.cost,
+		totalCost: m.totalCost,
+	}
+	c.lock.disabled = m.lock.disabled
+
+	for k, v := range m.data {
+		c.data[k] = v
+	}
+	copy(c.order, m.order)
+	for k, i := range m.index {
+		c.index[k] = i
+	}
+	if m.insertion != nil {
+		c.insertion = make([]string, len(m.insertion))
+		copy(c.insertion, m.insertion)
+	}
+	if m.added != nil {
+		c.added = make(map[string]time.Time, len(m.added))
+		for k, t := range m.added {
+			c.added[k] = t
+		}
+	}
+	return c
+}
+
+// Copy every item in this map into dst, in order.  Keys that already exist in
+// dst have their value updated in place, while new keys are added onto the
+// end.  It is safe to pass this map as dst.
+func (m *OrderedMap) CopyTo(dst *OrderedMap) {
+	entries := m.tuples()
+
+	dst.lock.Lock()
+	for _, t := range entries {
+		if _, ok := dst.data[t.Key]; !ok {
+			dst.appendKey(t.Key)
+			dst.recordInsertion(t.Key)
+		}
+		dst.store(t.Key, t.Val)
+	}
+	dst.evictOverCost()
+	dst.lock.Unlock()
+}
+
+// Split the map into two new maps at the given index.  The head will contain
+// all items before index, and the tail will contain the item at index and
+// everything after it, both in their current order.  The index may be anywhere
+// from 0 to Count(), and this map is left unchanged.
+func (m *OrderedMap) SplitAt(index int) (head, tail *OrderedMap, err error) {
+	entries := m.tuples()
+
+	if index > len(entries) {
+		return nil, nil, errors.New("Index is larger than the current map size.")
+	}
+
+	if index < 0 {
+		return nil, nil, errors.New("Index is less than 0.")
+	}
+
+	return fromTuples(entries[:index]), fromTuples(entries[index:]), nil
+}
+
+// Get up to the first n items in the map, in order.  If the map holds fewer
+// than n items, all of them are returned.
+func (m *OrderedMap) FirstN(n int) []Tuple {
+	m.lock.RLock()
+	if n > len(m.order) {
+		n = len(m.order)
+	}
+	if n < 0 {
+		n = 0
+	}
+	tmp := make([]Tuple, n)
+	for i, k := range m.order[:n] {
+		tmp[i] = Tuple{k, m.data[k]}
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Get up to the last n items in the map, in order.  If the map holds fewer
+// than n items, all of them are returned.
+func (m *OrderedMap) LastN(n int) []Tuple {
+	m.lock.RLock()
+	if n > len(m.order) {
+		n = len(m.order)
+	}
+	if n < 0 {
+		n = 0
+	}
+	tmp := make([]Tuple, n)
+	for i, k := range m.order[len(m.order)-n:] {
+		tmp[i] = Tuple{k, m.data[k]}
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Get a new map containing only the items for which keep returns true, in their
+// current order.  This map is left unchanged.
+func (m *OrderedMap) Filter(keep func(key string, value interface{}) bool) *OrderedMap {
+	return m.FilterAll(keep)
+}
+
+// Get a new map containing only the items that satisfy every one of the
+// provided predicates, in their current order.  With no predicates, every item
+// is kept.
+func (m *OrderedMap) FilterAll(preds ...func(key string, value interface{}) bool) *OrderedMap {
+	entries := m.tuples()
+	tmp := make([]Tuple, 0, len(entries))
+
+	for _, t := range entries {
+		keep := true
+		for _, pred := range preds {
+			if !pred(t.Key, t.Val) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			tmp = append(tmp, t)
+		}
+	}
+
+	return fromTuples(tmp)
+}
+
+// Get a new map containing only the items that satisfy at least one of the
+// provided predicates, in their current order.  With no predicates, no items
+// are kept.
+func (m *OrderedMap) FilterAny(preds ...func(key string, value interface{}) bool) *OrderedMap {
+	entries := m.tuples()
+	tmp := make([]Tuple, 0, len(entries))
+
+	for _, t := range entries {
+		for _, pred := range preds {
+			if pred(t.Key, t.Val) {
+				tmp = append(tmp, t)
+				break
+			}
+		}
+	}
+
+	return fromTuples(tmp)
+}
+
+// Get the keys of every item that satisfies pred, in order.  The items are
+// copied before pred is first called, so pred is free to use this map.
+func (m *OrderedMap) FilterKeys(pred func(key string, value interface{}) bool) []string {
+	tmp := make([]string, 0)
+	for _, t := range m.tuples() {
+		if pred(t.Key, t.Val) {
+			tmp = append(tmp, t.Key)
+		}
+	}
+	return tmp
+}
+
+// Get a new map holding the items of this map and other taken in turn, starting
+// with this map, followed by whatever remains of the longer one.  If a key is
+// found in both, it keeps the position where it was first added and takes the
+// value that was added last.  Neither map is changed.
+func (m *OrderedMap) Interleave(other *OrderedMap) *OrderedMap {
+	mine := m.tuples()
+	others := other.tuples()
+
+	res := New()
+	add := func(t Tuple) {
+		if _, ok := res.data[t.Key]; !ok {
+			res.appendKey(t.Key)
+		}
+		res.data[t.Key] = t.Val
+	}
+	for i := 0; i < len(mine) || i < len(others); i++ {
+		if i < len(mine) {
+			add(mine[i])
+		}
+		if i < len(others) {
+			add(others[i])
+		}
+	}
+	return res
+}
+
+// Get a new map with any nested *OrderedMap values collapsed into this level,
+// depth first, with their keys joined to their parent's key by sep.  For
+// example, a key "db" holding a map with the key "host" becomes "db.host" when
+// sep is ".".  Values that are not an *OrderedMap are kept as they are.
+func (m *OrderedMap) Flatten(sep string) *OrderedMap {
+	res := New()
+	m.flattenInto(res, "", sep)
+	return res
+}
+
+// Add the flattened items of this map to res, with keys prefixed by prefix.
+func (m *OrderedMap) flattenInto(res *OrderedMap, prefix, sep string) {
+	for _, t := range m.tuples() {
+		key := prefix + t.Key
+		if child, ok := t.Val.(*OrderedMap); ok {
+			child.flattenInto(res, key+sep, sep)
+			continue
+		}
+		if _, ok := res.data[key]; !ok {
+			res.appendKey(key)
+		}
+		res.data[key] = t.Val
+	}
+}
+
+// Get a new tree of nested maps built from keys joined by sep, reversing
+// Flatten.  For example, the key "db.host" becomes a key "host" inside a map
+// stored under "db" when sep is ".".  Keys keep the order they were first seen
+// at each level.  An error is returned if sep is empty or if a key is used both
+// as a value and as the parent of other keys.
+func (m *OrderedMap) Unflatten(sep string) (*OrderedMap, error) {
+	if sep == "" {
+		return nil, errors.New("Separator is empty.")
+	}
+
+	root := New()
+	nodes := map[*OrderedMap]bool{root: true}
+	for _, t := range m.tuples() {
+		parts := strings.Split(t.Key, sep)
+		node := root
+		for _, part := range parts[:len(parts)-1] {
+			val, ok := node.data[part]
+			if !ok {
+				child := New()
+				val = child
+				nodes[child] = true
+				node.data[part] = val
+				node.appendKey(part)
+			}
+			child, isNode := val.(*OrderedMap)
+			if !isNode || !nodes[child] {
+				return nil, fmt.Errorf("Key %q is used as both a value and a parent.", t.Key)
+			}
+			node = child
+		}
+
+		last := parts[len(parts)-1]
+		if val, ok := node.data[last]; ok {
+			if child, isNode := val.(*OrderedMap); isNode && nodes[child] {
+				return nil, fmt.Errorf("Key %q is used as both a value and a parent.", t.Key)
+			}
+		} else {
+			node.appendKey(last)
+		}
+		node.data[last] = t.Val
+	}
+
+	return root, nil
+}
+
+// Merge another map into this one, using resolve to decide the value of any
+// key that exists in both.  Keys that already exist keep their current
+// position, while keys only found in other are added onto the end in other's
+// order.  The resolve function is called while the map is locked, so it must
+// not call back into this map.
+func (m *OrderedMap) MergeFunc(other *OrderedMap, resolve func(key string, existing, incoming interface{}) interface{}) {
+	entries := other.tuples()
+
+	m.lock.Lock()
+	for _, t := range entries {
+		if existing, ok := m.data[t.Key]; ok {
+			m.store(t.Key, resolve(t.Key, existing, t.Val))
+		} else {
+			m.store(t.Key, t.Val)
+			m.appendKey(t.Key)
+			m.recordInsertion(t.Key)
+		}
+	}
+	m.evictOverCost()
+	m.lock.Unlock()
+}
+
+// Change the value of an existing key to the result of calling fn with its
+// current value, keeping its position in the map.  This is done under a single
+// lock, so it can be used to safely change a value from several goroutines at
+// once, such as incrementing a counter.  An error is returned if the key does
+// not exist.  The fn function is called while the map is locked, so it must
+// not call back into this map.
+func (m *OrderedMap) Update(key string, fn func(old interface{}) interface{}) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	old, ok := m.data[key]
+	if !ok {
+		return fmt.Errorf("Key %q does not exist.", key)
+	}
+	m.store(key, fn(old))
+	m.evictOverCost()
+	return nil
+}
+
+// Replace every value in the map that is equal to oldVal with newVal, returning
+// the number of values that were replaced.  Values are compared using eq, or
+// reflect.DeepEqual when eq is nil.  Keys and order are left unchanged.
+func (m *OrderedMap) ReplaceValue(oldVal, newVal interface{}, eq func(a, b interface{}) bool) int {
+	if eq == nil {
+		eq = reflect.DeepEqual
+	}
+
+	m.lock.Lock()
+	cnt := 0
+	for _, k := range m.order {
+		if eq(m.data[k], oldVal) {
+			m.store(k, newVal)
+			cnt++
+		}
+	}
+	m.evictOverCost()
+	m.lock.Unlock()
+	return cnt
+}
+
+// Walk the map in order, replacing the value of each item for which fn returns
+// true with the value fn returned, and returning the number of items that were
+// migrated.  Items for which fn returns false are left unchanged.  The fn
+// function is called while the map is locked, so it must not call back into
+// this map.
+func (m *OrderedMap) Migrate(fn func(key string, value interface{}) (interface{}, bool)) int {
+	m.lock.Lock()
+	cnt := 0
+	for _, k := range m.order {
+		if val, ok := fn(k, m.data[k]); ok {
+			m.store(k, val)
+			cnt++
+		}
+	}
+	m.evictOverCost()
+	m.lock.Unlock()
+	return cnt
+}
+
+// Change the name of an existing key, keeping its value and its position in the
+// map.  An error is returned if oldKey does not exist or newKey already does.
+func (m *OrderedMap) Rename(oldKey, newKey string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.rename(oldKey, newKey)
+}
+
+// Exchange the positions of two keys in the map, leaving every other item where
+// it is.  Values are left unchanged.  An error is returned if either key does
+// not exist.
+func (m *OrderedMap) Swap(keyA, keyB string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	a, ok := m.position(keyA)
+	if !ok {
+		return fmt.Errorf("Key %q does not exist.", keyA)
+	}
+	b, ok := m.position(keyB)
+	if !ok {
+		return fmt.Errorf("Key %q does not exist.", keyB)
+	}
+
+	m.order[a], m.order[b] = m.order[b], m.order[a]
+	m.index[keyA], m.index[keyB] = b+m.head, a+m.head
+	return nil
+}
+
+// Exchange the values stored under two keys, leaving both keys in their current
+// positions.  An error is returned if either key does not exist.
+func (m *OrderedMap) SwapValues(keyA, keyB string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	a, ok := m.data[keyA]
+	if !ok {
+		return fmt.Errorf("Key %q does not exist.", keyA)
+	}
+	b, ok := m.data[keyB]
+	if !ok {
+		return fmt.Errorf("Key %q does not exist.", keyB)
+	}
+
+	m.data[keyA] = b
+	m.data[keyB] = a
+	return nil
+}
+
+// Get groups of keys whose values are equal to each other.  Only groups with
+// more than one key are returned; keys within a group are in map order, and
+// groups are ordered by the position of their first key.  Values are compared
+// using eq, or reflect.DeepEqual when eq is nil.
+func (m *OrderedMap) DuplicateValues(eq func(a, b interface{}) bool) [][]string {
+	if eq == nil {
+		eq = reflect.DeepEqual
+	}
+
+	entries := m.tuples()
+	grouped := make([]bool, len(entries))
+	tmp := make([][]string, 0)
+	for i, t := range entries {
+		if grouped[i] {
+			continue
+		}
+		group := []string{t.Key}
+		for j := i + 1; j < len(entries); j++ {
+			if !grouped[j] && eq(t.Val, entries[j].Val) {
+				group = append(group, entries[j].Key)
+				grouped[j] = true
+			}
+		}
+		if len(group) > 1 {
+			tmp = append(tmp, group)
+		}
+	}
+	return tmp
+}
+
+// Remove every item whose value is equal to the value of an item before it,
+// so that only the first item with each distinct value is kept, returning the
+// number of items removed.  Values are compared using eq, or reflect.DeepEqual
+// when eq is nil.  The eq function is called while the map is locked, so it
+// must not call back into this map.
+func (m *OrderedMap) DedupeByValue(eq func(a, b interface{}) bool) int {
+	if eq == nil {
+		eq = reflect.DeepEqual
 	}
 
 	m.lock.Lock()
-	m.data[key] = value
-	pre := m.order[:position]
-	post := m.order[position:]
-	m.order = make([]string, len(pre))
-	copy(m.order, pre)
-	m.order = append(m.order, key)
-	m.order = append(m.order, post...)
+	kept := make([]interface{}, 0)
+	tmp := make([]string, 0, len(m.order))
+	for _, k := range m.order {
+		val := m.data[k]
+		dup := false
+		for _, v := range kept {
+			if eq(v, val) {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			m.discard(k)
+		} else {
+			kept = append(kept, val)
+			tmp = append(tmp, k)
+		}
+	}
+	cnt := len(m.order) - len(tmp)
+	m.order = tmp
+	m.rebuildIndex()
 	m.lock.Unlock()
-
-	return nil
+	return cnt
 }
 
-// Get a specific object out of the map based on its map key.  In the event the
-// key does not exist or the data is out of range, the function will have a
-// second return of false.
-//
-// Get key:
-// 	data, ok := om.GetKey("mykey")
-//
-// Test if key exists:
-// 	if _, ok := om.GetKey("mykey"); ok {
-// 		... DO SOMETHING HERE ...
-// 	}
-func (m OrderedMap) GetKey(key string) (interface{}, bool) {
+// Get the keys that exist in both this map and other, in this map's order.
+// It is safe to pass this map as other.
+func (m *OrderedMap) IntersectKeys(other *OrderedMap) []string {
+	others := keySet(other.GetOrder())
+
 	m.lock.RLock()
-	data, ok := m.data[key]
+	tmp := make([]string, 0)
+	for _, k := range m.order {
+		if others[k] {
+			tmp = append(tmp, k)
+		}
+	}
 	m.lock.RUnlock()
-	return data, ok
+	return tmp
 }
 
-// Get a specific object and it's key out of the map based on it's order index,
-// with 0 being the first item in the order.  Will return a false in the event
-// The key does not exist.
-func (m OrderedMap) GetIndex(index int) (string, interface{}, bool) {
-	m.lock.RLock()
-	key := m.order[index]
-	data, ok := m.data[key]
-	m.lock.RUnlock()
-	return key, data, ok
+// Get the keys that exist in either this map or other.  Keys from this map
+// come first in its order, followed by keys only found in other in its order.
+// It is safe to pass this map as other.
+func (m *OrderedMap) UnionKeys(other *OrderedMap) []string {
+	others := other.GetOrder()
+	tmp := m.GetOrder()
+	mine := keySet(tmp)
+
+	for _, k := range others {
+		if !mine[k] {
+			tmp = append(tmp, k)
+		}
+	}
+	return tmp
 }
 
-// Get a slice of strings containing the current order of the array
-func (m OrderedMap) GetOrder() []string {
+// Get the keys that exist in this map but not in other, in this map's order.
+// It is safe to pass this map as other.
+func (m *OrderedMap) DifferenceKeys(other *OrderedMap) []string {
+	others := keySet(other.GetOrder())
+
 	m.lock.RLock()
-	tmp := make([]string, len(m.order))
-	copy(tmp, m.order)
+	tmp := make([]string, 0)
+	for _, k := range m.order {
+		if !others[k] {
+			tmp = append(tmp, k)
+		}
+	}
 	m.lock.RUnlock()
 	return tmp
 }
 
-// Set a new order for this map.  SetOrder will return an error if either the
-// number of items in the provided slice is different than those in the map, or
-// if the keys are different that those currently in use.
-func (m *OrderedMap) SetOrder(order []string) error {
-	if !compareOrder(m.order, order) {
-		return errors.New("Provided order does not contain the same data as existing.")
+// Get the keys that exist in only one of this map and other.  Keys only found
+// in this map come first in its order, followed by keys only found in other in
+// its order.  It is safe to pass this map as other.
+func (m *OrderedMap) SymmetricDifferenceKeys(other *OrderedMap) []string {
+	others := other.GetOrder()
+	mine := m.GetOrder()
+	otherSet := keySet(others)
+	mineSet := keySet(mine)
+
+	tmp := make([]string, 0)
+	for _, k := range mine {
+		if !otherSet[k] {
+			tmp = append(tmp, k)
+		}
 	}
-	m.lock.Lock()
-	copy(m.order, order)
-	m.lock.Unlock()
-	return nil
+	for _, k := range others {
+		if !mineSet[k] {
+			tmp = append(tmp, k)
+		}
+	}
+	return tmp
+}
+
+// Test if this map and other have exactly the same keys in exactly the same
+// order, ignoring their values.  It is safe to pass this map as other.
+func (m *OrderedMap) SameOrder(other *OrderedMap) bool {
+	others := other.GetOrder()
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if len(m.order) != len(others) {
+		return false
+	}
+	for i, k := range m.order {
+		if others[i] != k {
+			return false
+		}
+	}
+	return true
 }
 
-// Get the order index of a specific key
-func (m OrderedMap) IndexOf(key string) int {
+// Test if this map and other have the same keys in the same order, with equal
+// values.  Values are compared using reflect.DeepEqual.  It is safe to pass this
+// map as other.
+func (m *OrderedMap) Equal(other *OrderedMap) bool {
+	others := other.tuples()
+
 	m.lock.RLock()
-	index := -1
-	for i := 0; i < len(m.order); i++ {
-		if m.order[i] == key {
-			index = i
+	defer m.lock.RUnlock()
+	if len(m.order) != len(others) {
+		return false
+	}
+	for i, k := range m.order {
+		if others[i].Key != k || !reflect.DeepEqual(others[i].Val, m.data[k]) {
+			return false
 		}
 	}
-	m.lock.RUnlock()
-	return index
+	return true
 }
 
-// Delete a specific key and all associated data from the map
-func (m *OrderedMap) Delete(key string) {
-	idx := m.IndexOf(key)
+// Test if this map and other have the same keys with equal values, in any
+// order.  Values are compared using reflect.DeepEqual.  It is safe to pass this
+// map as other.
+func (m *OrderedMap) EqualUnordered(other *OrderedMap) bool {
+	others := other.tuples()
+	keys := make([]string, len(others))
+	for i, t := range others {
+		keys[i] = t.Key
+	}
 
-	m.lock.Lock()
-	delete(m.data, key)
-	tmp := make([]string, len(m.order))
-	copy(tmp, m.order)
-	m.order = make([]string, len(tmp))
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if !compareOrder(m.order, keys) {
+		return false
+	}
+	for _, t := range others {
+		if !reflect.DeepEqual(t.Val, m.data[t.Key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Convert every value in the map to the type V, in order.  If any value is not
+// of type V, an error naming the first such key is returned instead.
+//
+// 	items, err := orderedmap.AsTyped[TestData](om)
+// 	if err != nil {
+// 		... HANDLE ERROR ...
+// 	}
+func AsTyped[V any](m *OrderedMap) ([]struct {
+	Key string
+	Val V
+}, error) {
+	entries := m.tuples()
+	tmp := make([]struct {
+		Key string
+		Val V
+	}, len(entries))
 
-	m.order = append(tmp[:idx], tmp[idx+1:]...)
+	for i, t := range entries {
+		val, ok := t.Val.(V)
+		if !ok {
+			return nil, fmt.Errorf("Value for key %q is of type %T, not the requested type.", t.Key, t.Val)
+		}
+		tmp[i].Key = t.Key
+		tmp[i].Val = val
+	}
+
+	return tmp, nil
+}
+
+// Get the map as url.Values, converting each value to a string with stringify,
+// or fmt.Sprint when stringify is nil.  Since url.Values.Encode sorts its keys,
+// the keys are also returned in order so that an ordered query string can be
+// built from them.
+func (m *OrderedMap) ToURLValues(stringify func(interface{}) string) (url.Values, []string) {
+	if stringify == nil {
+		stringify = func(v interface{}) string { return fmt.Sprint(v) }
+	}
+
+	entries := m.tuples()
+	vals := make(url.Values, len(entries))
+	keys := make([]string, len(entries))
+	for i, t := range entries {
+		vals.Set(t.Key, stringify(t.Val))
+		keys[i] = t.Key
+	}
+	return vals, keys
+}
+
+// Convert every item in the map using fn, in order, collecting the results.  If
+// fn returns an error, conversion stops and that error is returned instead.
+// The items are copied before fn is first called, so fn is free to use the map.
+func CollectInto[T any](m *OrderedMap, fn func(key string, value interface{}) (T, error)) ([]T, error) {
+	entries := m.tuples()
+	tmp := make([]T, 0, len(entries))
+	for _, t := range entries {
+		res, err := fn(t.Key, t.Val)
+		if err != nil {
+			return nil, err
+		}
+		tmp = append(tmp, res)
+	}
+	return tmp, nil
+}
+
+// Remove every item from the map, leaving it empty and ready to be reused.
+func (m *OrderedMap) Clear() {
+	m.lock.Lock()
+	m.reset()
 	m.lock.Unlock()
 }
 
-// Get the total size of the map
-func (m OrderedMap) Count() int {
-	m.lock.RLock()
-	cnt := len(m.data)
-	m.lock.RUnlock()
-	return cnt
+// Get every item in the map, in order, and empty the map in the same locked
+// step, so that no items added in between can be lost.
+func (m *OrderedMap) Drain() []Tuple {
+	m.lock.Lock()
+	tmp := make([]Tuple, len(m.order))
+	for i, k := range m.order {
+		tmp[i] = Tuple{k, m.data[k]}
+	}
+	m.reset()
+	m.lock.Unlock()
+	return tmp
 }
 
 // A struct used to provide the ability to loop through all items in the
@@ -165,7 +1982,9 @@ func (m OrderedMap) Count() int {
 type OrderedMapIterator struct {
 	returnchan chan Tuple
 	breakchan  chan bool
+	done       chan struct{}
 	data       *OrderedMap
+	ctx        context.Context
 }
 
 // A data structure to hold returned information on each iteration
@@ -182,12 +2001,32 @@ type Tuple struct {
 // to range and will return a Tuple for each item in the map.
 //
 // IMPORTANT NOTE: You must use the Break() function before you use the break
-// go command, otherwise you might have deadlock, race, or garbage issues.
+// go command, otherwise you might have deadlock, race, or garbage issues.  If
+// you cannot guarantee that, use IteratorContext instead.
 func (m *OrderedMap) Iterator() OrderedMapIterator {
+	return m.IteratorContext(context.Background())
+}
+
+// Returns an OrderedMapIterator type that works the same way as Iterator, but
+// that also stops looping once ctx is cancelled.  This means a loop that is
+// abandoned without calling Break() does not leave anything running, as long as
+// ctx is cancelled afterwards.
+//
+// 	ctx, cancel := context.WithCancel(context.Background())
+// 	defer cancel()
+// 	iter = mymap.IteratorContext(ctx)
+// 	for data := range iter.Loop() {
+// 		if data.Key == "two" {
+// 			return
+// 		}
+// 	}
+func (m *OrderedMap) IteratorContext(ctx context.Context) OrderedMapIterator {
 	return OrderedMapIterator{
 		returnchan: make(chan Tuple),
 		breakchan:  make(chan bool),
+		done:       make(chan struct{}),
 		data:       m,
+		ctx:        ctx,
 	}
 }
 
@@ -200,37 +2039,108 @@ func (m *OrderedMap) Iterator() OrderedMapIterator {
 // 		fmt.Printf("%s > %v\n", data.Key, data.Val)
 // 	}
 func (it *OrderedMapIterator) Loop() <-chan Tuple {
-	go func() {
-		max := it.data.Count()
-
-		for i := 0; i < max; i++ {
-			k, v, ok := it.data.GetIndex(i)
-			if ok {
-				select {
-				case it.returnchan <- Tuple{k, v}:
-				case <-it.breakchan:
-					close(it.returnchan)
-					return
-				}
-			}
-		}
-
-		close(it.returnchan)
-		close(it.breakchan)
-	}()
+	go it.run(false)
+	return it.returnchan
+}
 
+// Provides access to a channel that will allow looping through the entire
+// map in reverse order, from the last item to the first.  This works the same
+// way as Loop, including the need to call Break before breaking out early.
+//
+// 	iter = mymap.Iterator()
+// 	for data := range iter.LoopReverse() {
+// 		fmt.Printf("%s > %v\n", data.Key, data.Val)
+// 	}
+func (it *OrderedMapIterator) LoopReverse() <-chan Tuple {
+	go it.run(true)
 	return it.returnchan
 }
 
+// Send every item in the map to the iterator's channel, either from first to
+// last or the other way around, until every item has been sent, Break() is
+// called, or the iterator's context is cancelled.  The items are copied when
+// looping starts.  The done channel is closed on the way out, so that a Break
+// that comes too late does not block.
+func (it *OrderedMapIterator) run(reverse bool) {
+	defer close(it.done)
+	defer close(it.returnchan)
+
+	entries := it.data.tuples()
+
+	for n := range entries {
+		t := entries[n]
+		if reverse {
+			t = entries[len(entries)-1-n]
+		}
+		select {
+		case it.returnchan <- t:
+		case <-it.breakchan:
+			return
+		case <-it.ctx.Done():
+			return
+		}
+	}
+}
+
 // Signals the iterator that you no longer want to loop, allowing us to clean
 // up, stop looping, and allows the garbage collector to clean up.  Finally,
 // also makes sure all channels are closed and all mutex locks are clean, so
 // that there are no issues with deadlocks.
 func (it *OrderedMapIterator) Break() {
 	select {
-	case _, _ = <-it.breakchan:
-	default:
-		it.breakchan <- true
+	case it.breakchan <- true:
+	case <-it.done:
+	case <-it.ctx.Done():
+	}
+}
+
+// Provides a channel that streams every item in the map, in order, as a Tuple.
+// The channel holds up to buffer items that have not been received yet, and is
+// closed once every item has been sent or ctx is cancelled, whichever is first.
+// Unlike Iterator, there is no need to call Break; cancelling ctx is enough to
+// clean up.  The items are copied from the map when Stream is called.
+//
+// 	ctx, cancel := context.WithCancel(context.Background())
+// 	defer cancel()
+// 	for data := range mymap.Stream(ctx, 10) {
+// 		fmt.Printf("%s > %v\n", data.Key, data.Val)
+// 	}
+func (m *OrderedMap) Stream(ctx context.Context, buffer int) <-chan Tuple {
+	if buffer < 0 {
+		buffer = 0
+	}
+	entries := m.tuples()
+	out := make(chan Tuple, buffer)
+
+	go func() {
+		defer close(out)
+		for _, t := range entries {
+			select {
+			case out <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Get an iterator over every item in the map, in order, for use with range.
+// Unlike Iterator, breaking out of the loop early needs no cleanup.  The items
+// are copied from the map when the loop starts, so the map is free to be
+// changed while looping, including from inside the loop.
+//
+// 	for key, val := range mymap.All() {
+// 		fmt.Printf("%s > %v\n", key, val)
+// 	}
+func (m *OrderedMap) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for _, t := range m.tuples() {
+			if !yield(t.Key, t.Val) {
+				return
+			}
+		}
 	}
 }
 
@@ -259,3 +2169,294 @@ func compareOrder(f []string, s []string) bool {
 
 	return true
 }
+
+// Get a copy of every item in the map, in order, as a slice of Tuples.  This
+// takes the read lock for the duration of the copy only, so the result can be
+// used safely while locking another map, including this one.
+func (m *OrderedMap) tuples() []Tuple {
+	m.lock.RLock()
+	tmp := make([]Tuple, len(m.order))
+	for i, k := range m.order {
+		tmp[i] = Tuple{k, m.data[k]}
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Remove every item from the map, keeping the space already allocated for the
+// order so it can be reused.  The caller must hold the write lock.
+func (m *OrderedMap) reset() {
+	m.data = make(map[string]interface{})
+	m.order = m.order[:0]
+	m.index = make(map[string]int)
+	m.head = 0
+	if m.insertion != nil {
+		m.insertion = m.insertion[:0]
+	}
+	if m.added != nil {
+		m.added = make(map[string]time.Time)
+	}
+	m.totalCost = 0
+}
+
+// Split the order into the keys whose items satisfy pred and those that do not,
+// both in their current order.  The caller must hold the lock.
+func (m *OrderedMap) partition(pred func(key string, value interface{}) bool) (matched, rest []string) {
+	matched = make([]string, 0, len(m.order))
+	rest = make([]string, 0, len(m.order))
+	for _, k := range m.order {
+		if pred(k, m.data[k]) {
+			matched = append(matched, k)
+		} else {
+			rest = append(rest, k)
+		}
+	}
+	return matched, rest
+}
+
+// Replace everything in the map with the provided Tuples, in order.  If a key
+// is repeated, it keeps its first position and takes its last value.
+func (m *OrderedMap) load(entries []Tuple) {
+	m.lock.Lock()
+	m.reset()
+	for _, t := range entries {
+		if _, ok := m.data[t.Key]; !ok {
+			m.appendKey(t.Key)
+			m.recordInsertion(t.Key)
+		}
+		m.store(t.Key, t.Val)
+	}
+	m.evictOverCost()
+	m.lock.Unlock()
+}
+
+// Remove the item at a specific order index, returning its key.  The caller
+// must hold the write lock and make sure the index is in range.
+func (m *OrderedMap) removeIndex(index int) string {
+	key := m.order[index]
+	m.removeKeyAt(index)
+	m.discard(key)
+	return key
+}
+
+// Add an object to the map at offset from the position of an existing anchor
+// key, where 0 is directly before it and 1 is directly after it.
+func (m *OrderedMap) insertNextTo(anchor, key string, value interface{}, offset int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.data[anchor]; !ok {
+		return fmt.Errorf("Key %q does not exist.", anchor)
+	}
+
+	if key != anchor {
+		if _, ok := m.data[key]; ok {
+			m.removeFromOrder(key)
+		} else {
+			m.recordInsertion(key)
+		}
+		index, _ := m.position(anchor)
+		m.insertKeyAt(index+offset, key)
+	}
+	m.store(key, value)
+	m.evictOverCost()
+	return nil
+}
+
+// Give an existing key a new name, keeping its value, position, and any other
+// tracking for it.  The caller must hold the write lock.
+func (m *OrderedMap) rename(oldKey, newKey string) error {
+	val, ok := m.data[oldKey]
+	if !ok {
+		return fmt.Errorf("Key %q does not exist.", oldKey)
+	}
+	if newKey == oldKey {
+		return nil
+	}
+	if _, ok := m.data[newKey]; ok {
+		return fmt.Errorf("Key %q already exists.", newKey)
+	}
+	delete(m.data, oldKey)
+	m.data[newKey] = val
+	i := m.index[oldKey]
+	delete(m.index, oldKey)
+	m.order[i-m.head] = newKey
+	m.index[newKey] = i
+	for i, k := range m.insertion {
+		if k == oldKey {
+			m.insertion[i] = newKey
+			break
+		}
+	}
+	if t, ok := m.added[oldKey]; ok {
+		delete(m.added, oldKey)
+		m.added[newKey] = t
+	}
+	return nil
+}
+
+// Store a value for a key, keeping track of the total cost of the map's values
+// if it is size bounded.  This does not touch the order.  The caller must hold
+// the write lock.
+func (m *OrderedMap) store(key string, value interface{}) {
+	if m.cost != nil {
+		if old, ok := m.data[key]; ok {
+			m.totalCost -= m.cost(old)
+		}
+		m.totalCost += m.cost(value)
+	}
+	m.data[key] = value
+}
+
+// Remove the data for a key, along with any other tracking for it, except for
+// its place in the order.  The caller must hold the write lock.
+func (m *OrderedMap) discard(key string) {
+	if old, ok := m.data[key]; ok && m.cost != nil {
+		m.totalCost -= m.cost(old)
+	}
+	delete(m.data, key)
+	m.forgetInsertion(key)
+}
+
+// Remove items from the front of the map until the total cost of its values is
+// within budget, if it is size bounded.  The caller must hold the write lock.
+func (m *OrderedMap) evictOverCost() {
+	if m.cost == nil {
+		return
+	}
+	for m.totalCost > m.maxCost && len(m.order) > 0 {
+		m.removeIndex(0)
+	}
+}
+
+// Remove a key from the order, leaving its data in place.  The caller must
+// hold the write lock.
+func (m *OrderedMap) removeFromOrder(key string) {
+	if i, ok := m.position(key); ok {
+		m.removeKeyAt(i)
+	}
+}
+
+// Get the current order index of a key from the index.  The index holds each
+// key's order index plus head, so that removing the first key only needs head
+// to be moved along rather than every other entry being rewritten.  The caller
+// must hold the lock.
+func (m *OrderedMap) position(key string) (int, bool) {
+	i, ok := m.index[key]
+	return i - m.head, ok
+}
+
+// Add a key onto the end of the order.  The caller must hold the write lock.
+func (m *OrderedMap) appendKey(key string) {
+	if _, ok := m.index[key]; !ok {
+		m.index[key] = len(m.order) + m.head
+	}
+	m.order = append(m.order, key)
+}
+
+// Add a key into the order at a specific index, moving everything after it
+// back by one.  The caller must hold the write lock and make sure the index is
+// in range.
+func (m *OrderedMap) insertKeyAt(index int, key string) {
+	m.order = append(m.order, "")
+	copy(m.order[index+1:], m.order[index:])
+	m.order[index] = key
+	m.reindex(index)
+}
+
+// Remove the key at a specific order index, moving everything after it forward
+// by one.  Only the keys on the shorter side of index have their entries in the
+// index rewritten, so removing from either end is cheap.  The caller must hold
+// the write lock and make sure the index is in range.
+func (m *OrderedMap) removeKeyAt(index int) {
+	if len(m.order) > len(m.index) {
+		// Some key is in the order more than once, which the shortcuts below
+		// do not allow for.
+		m.order = append(m.order[:index], m.order[index+1:]...)
+		m.rebuildIndex()
+		return
+	}
+
+	delete(m.index, m.order[index])
+	if index < len(m.order)/2 {
+		for _, k := range m.order[:index] {
+			m.index[k]++
+		}
+		copy(m.order[1:index+1], m.order[:index])
+		m.order[0] = ""
+		m.order = m.order[1:]
+		m.head++
+	} else {
+		for _, k := range m.order[index+1:] {
+			m.index[k]--
+		}
+		m.order = append(m.order[:index], m.order[index+1:]...)
+	}
+}
+
+// Update the index of every key from a specific order index onwards, after the
+// order has changed from that point.  A key that appears more than once keeps
+// the index of its first appearance.  The caller must hold the write lock.
+func (m *OrderedMap) reindex(from int) {
+	for i := from; i < len(m.order); i++ {
+		k := m.order[i]
+		if j, ok := m.position(k); ok && j >= 0 && j < i && m.order[j] == k {
+			continue
+		}
+		m.index[k] = i + m.head
+	}
+}
+
+// Throw away the index and build it again from the current order.  This is
+// used after the order has been rearranged as a whole, or keys removed from
+// it.  The caller must hold the write lock.
+func (m *OrderedMap) rebuildIndex() {
+	m.index = make(map[string]int, len(m.order))
+	m.head = 0
+	m.reindex(0)
+}
+
+// Record a newly added key in the insertion order and its time, if this map is
+// tracking them.  The caller must hold the write lock.
+func (m *OrderedMap) recordInsertion(key string) {
+	if m.insertion != nil {
+		m.insertion = append(m.insertion, key)
+	}
+	if m.added != nil {
+		m.added[key] = m.now()
+	}
+}
+
+// Remove a key from the insertion order and its time, if this map is tracking
+// them.  The caller must hold the write lock.
+func (m *OrderedMap) forgetInsertion(key string) {
+	delete(m.added, key)
+	if m.insertion == nil {
+		return
+	}
+	for i, k := range m.insertion {
+		if k == key {
+			m.insertion = append(m.insertion[:i], m.insertion[i+1:]...)
+			return
+		}
+	}
+}
+
+// Create a new ordered map containing the provided Tuples, in order.
+func fromTuples(entries []Tuple) *OrderedMap {
+	m := New()
+	for _, t := range entries {
+		m.data[t.Key] = t.Val
+		m.appendKey(t.Key)
+	}
+	return m
+}
+
+// Build a set out of a slice of keys for quick lookups.
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
@@ -4,10 +4,17 @@
 package orderedmap
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type TestData struct {
@@ -17,7 +24,7 @@ type TestData struct {
 
 func TestNewOrderedMap(t *testing.T) {
 	om := New()
-	if reflect.TypeOf(om).Name() != "OrderedMap" {
+	if reflect.TypeOf(om).Elem().Name() != "OrderedMap" {
 
 		t.Error("Map is not the correct type")
 	}
@@ -27,6 +34,167 @@ func TestNewOrderedMap(t *testing.T) {
 	}
 }
 
+func TestFromSlices(t *testing.T) {
+	om, err := FromSlices([]string{"one", "two", "three"}, []interface{}{1, 2, 3})
+	if err != nil {
+		t.Fatal("Error creating map from slices: " + err.Error())
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "one,two,three" {
+		t.Errorf("Order was wrong: %s", got)
+	}
+	if val, _ := om.GetKey("two"); val.(int) != 2 {
+		t.Error("Wrong item was returned from map")
+	}
+
+	_, err = FromSlices([]string{"one", "two"}, []interface{}{1})
+	if err == nil {
+		t.Error("No error was received when the slices were different lengths.")
+	}
+
+	_, err = FromSlices([]string{"one", "two", "one"}, []interface{}{1, 2, 3})
+	if err == nil {
+		t.Error("No error was received when a key was duplicated.")
+	}
+}
+
+func TestNewWithCapacity(t *testing.T) {
+	om := NewWithCapacity(100)
+	if om.Count() != 0 {
+		t.Error("New map was not empty")
+	}
+	if cap(om.order) != 100 {
+		t.Error("Order was not allocated with the requested capacity")
+	}
+	for i := 0; i < 150; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+	if om.Count() != 150 || om.IndexOf("149") != 149 {
+		t.Error("Map did not grow past its capacity")
+	}
+}
+
+func TestNewUnsafe(t *testing.T) {
+	om := NewUnsafe()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	if om.Count() != 2 {
+		t.Error("Map does not contain two items")
+	}
+	if key, _, _ := om.GetIndex(1); key != "two" {
+		t.Error("Wrong item was returned from map")
+	}
+	om.Delete("one")
+	if _, ok := om.GetKey("one"); ok {
+		t.Error("Deleted key still exists")
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var om OrderedMap
+	if om.Count() != 0 {
+		t.Error("Zero value map was not empty")
+	}
+	if _, ok := om.GetKey("one"); ok {
+		t.Error("Zero value map returned a key")
+	}
+	if om.IndexOf("one") != -1 {
+		t.Error("Index of a key in a zero value map was not -1")
+	}
+
+	if err := om.UnmarshalJSON([]byte(`{"one":1,"two":2}`)); err != nil {
+		t.Fatal("Error unmarshalling into a zero value map: " + err.Error())
+	}
+	om.Set("three", 3)
+	if strings.Join(om.GetOrder(), ",") != "one,two,three" {
+		t.Errorf("Order was wrong after unmarshalling: %v", om.GetOrder())
+	}
+}
+
+func TestNewSizeBounded(t *testing.T) {
+	om := NewSizeBounded(10, func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+
+	om.Add("a", "xxxx")
+	om.Add("b", "xxx")
+	om.Add("c", "xx")
+	if om.Count() != 3 {
+		t.Error("Items were evicted while under budget")
+	}
+
+	om.Add("d", "xxxxx")
+	if got := strings.Join(om.GetOrder(), ","); got != "b,c,d" {
+		t.Errorf("Wrong items were evicted: %s", got)
+	}
+
+	om.Delete("c")
+	om.Add("e", "xxxxx")
+	if got := strings.Join(om.GetOrder(), ","); got != "d,e" {
+		t.Errorf("Deleted item was still counted against the budget: %s", got)
+	}
+
+	om.Add("f", "xxxxxxxxxxx")
+	if om.Count() != 0 {
+		t.Error("An item over budget by itself was kept")
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	data := map[string]interface{}{
+		"delta":   4,
+		"alpha":   1,
+		"charlie": 3,
+		"bravo":   2,
+	}
+
+	om := FromMap(data)
+	if strings.Join(om.GetOrder(), ",") != "alpha,bravo,charlie,delta" {
+		t.Errorf("Order was not alphabetical: %v", om.GetOrder())
+	}
+	if val, _ := om.GetKey("charlie"); val != 3 {
+		t.Error("Value for charlie was wrong")
+	}
+
+	data["echo"] = 5
+	if om.Has("echo") {
+		t.Error("Changing the source map changed the ordered map")
+	}
+}
+
+func TestCountOf(t *testing.T) {
+	om := CountOf([]string{"b", "a", "b", "c", "a", "b"})
+
+	if got := strings.Join(om.GetOrder(), ","); got != "b,a,c" {
+		t.Errorf("Order was not first seen order: %s", got)
+	}
+
+	expected := map[string]int{"a": 2, "b": 3, "c": 1}
+	for k, v := range expected {
+		if got, _ := om.GetKey(k); got.(int) != v {
+			t.Errorf("Count for %s was %v, expected %d", k, got, v)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	om := New()
+	om.Set("one", TestData{ID: 1, Name: "one"})
+	om.Set("two", TestData{ID: 2, Name: "two"})
+	om.Set("three", TestData{ID: 3, Name: "three"})
+	om.Set("one", TestData{ID: 10, Name: "ten"})
+
+	if om.Count() != 3 {
+		t.Error("Map does not contain three items")
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "one,two,three" {
+		t.Errorf("Updated key was moved or duplicated: %s", got)
+	}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 10 {
+		t.Error("Value was not updated")
+	}
+}
+
 func TestAdd(t *testing.T) {
 	om := New()
 	one := TestData{ID: 1, Name: "one"}
@@ -40,6 +208,85 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestAddValidated(t *testing.T) {
+	om := New()
+	positive := func(value interface{}) error {
+		if value.(TestData).ID <= 0 {
+			return errors.New("ID must be positive")
+		}
+		return nil
+	}
+
+	err := om.AddValidated("one", TestData{ID: 1, Name: "one"}, positive)
+	if err != nil {
+		t.Error("Error adding a valid item: " + err.Error())
+	}
+	if val, ok := om.GetKey("one"); !ok || val.(TestData).ID != 1 {
+		t.Error("Valid item was not added")
+	}
+
+	err = om.AddValidated("one", TestData{ID: -1, Name: "bad"}, positive)
+	if err == nil {
+		t.Error("No error was received when adding an invalid item.")
+	}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 1 {
+		t.Error("Existing item was changed by an invalid update")
+	}
+
+	err = om.AddValidated("two", TestData{ID: 0, Name: "two"}, positive)
+	if err == nil {
+		t.Error("No error was received when adding an invalid item.")
+	}
+	if om.Count() != 1 || len(om.GetOrder()) != 1 {
+		t.Error("Invalid item was added")
+	}
+}
+
+func TestAddOrMoveToBack(t *testing.T) {
+	om := New()
+	om.AddOrMoveToBack("one", 1)
+	om.AddOrMoveToBack("two", 2)
+	om.AddOrMoveToBack("three", 3)
+	om.AddOrMoveToBack("one", 10)
+
+	if got := strings.Join(om.GetOrder(), ","); got != "two,three,one" {
+		t.Errorf("Existing key was not moved to the back: %s", got)
+	}
+	if om.Count() != 3 {
+		t.Error("Map does not contain three items")
+	}
+	if val, _ := om.GetKey("one"); val.(int) != 10 {
+		t.Error("Value was not updated")
+	}
+}
+
+func TestAddBounded(t *testing.T) {
+	om := New()
+	for i := 1; i <= 3; i++ {
+		if _, evicted := om.AddBounded(strconv.Itoa(i), i, 3); evicted {
+			t.Errorf("Item was evicted while adding %d under capacity", i)
+		}
+	}
+
+	if _, evicted := om.AddBounded("2", 20, 3); evicted {
+		t.Error("Item was evicted while updating an existing key")
+	}
+
+	key, evicted := om.AddBounded("4", 4, 3)
+	if !evicted || key != "1" {
+		t.Errorf("Wrong item was evicted: %s", key)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "2,3,4" {
+		t.Errorf("Order was wrong after eviction: %s", got)
+	}
+	if _, ok := om.GetKey("1"); ok {
+		t.Error("Evicted key still exists")
+	}
+	if val, _ := om.GetKey("2"); val.(int) != 20 {
+		t.Error("Updated value was lost")
+	}
+}
+
 func TestInsert(t *testing.T) {
 	om := New()
 
@@ -74,171 +321,2782 @@ func TestInsert(t *testing.T) {
 	}
 }
 
-func TestGetKey(t *testing.T) {
+func TestInsertAtEnd(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	err := om.Insert(om.Count(), "three", TestData{ID: 3, Name: "three"})
+	if err != nil {
+		t.Error("Error trying to insert at the end of ordered map: " + err.Error())
+	}
+
+	tmp := om.GetOrder()
+	if len(tmp) != 3 || tmp[2] != "three" {
+		t.Logf("Order: %v\n", tmp)
+		t.Error("Item was not inserted at the end")
+	}
+
+	err = om.Insert(om.Count()+1, "four", TestData{ID: 4, Name: "four"})
+	if err == nil {
+		t.Error("No error was received when trying to insert past the end.")
+	}
+}
+
+func TestInsertBefore(t *testing.T) {
+	om := New()
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+
+	if err := om.InsertBefore("two", "one", TestData{ID: 1, Name: "one"}); err != nil {
+		t.Error("Error inserting before the first key: " + err.Error())
+	}
+	if err := om.InsertBefore("three", "four", TestData{ID: 40, Name: "forty"}); err != nil {
+		t.Error("Error inserting an existing key: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,two,four,three" {
+		t.Errorf("Order was wrong after inserting before: %v", om.GetOrder())
+	}
+	if val, _ := om.GetKey("four"); val.(TestData).ID != 40 {
+		t.Error("Value of the existing key was not updated")
+	}
+	if err := om.InsertBefore("five", "six", TestData{ID: 6, Name: "six"}); err == nil {
+		t.Error("No error was received when inserting before a missing key.")
+	}
+	if om.Count() != 4 {
+		t.Error("Map does not contain correct number of items")
+	}
+}
+
+func TestInsertAfter(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	if err := om.InsertAfter("three", "four", TestData{ID: 4, Name: "four"}); err != nil {
+		t.Error("Error inserting after the last key: " + err.Error())
+	}
+	if err := om.InsertAfter("two", "one", TestData{ID: 1, Name: "one"}); err != nil {
+		t.Error("Error inserting an existing key: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "two,one,three,four" {
+		t.Errorf("Order was wrong after inserting after: %v", om.GetOrder())
+	}
+	if err := om.InsertAfter("five", "six", TestData{ID: 6, Name: "six"}); err == nil {
+		t.Error("No error was received when inserting after a missing key.")
+	}
+}
+
+func TestInsertExisting(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+
+	err := om.Insert(2, "one", TestData{ID: 10, Name: "ten"})
+	if err != nil {
+		t.Error("Error trying to insert an existing key: " + err.Error())
+	}
+	if om.Count() != 4 {
+		t.Error("Map does not contain correct number of items")
+	}
+	if strings.Join(om.GetOrder(), ",") != "two,three,one,four" {
+		t.Errorf("Order was wrong after moving forward: %v", om.GetOrder())
+	}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 10 {
+		t.Error("Value of the existing key was not updated")
+	}
+
+	om.Insert(0, "four", TestData{ID: 4, Name: "four"})
+	if strings.Join(om.GetOrder(), ",") != "four,two,three,one" {
+		t.Errorf("Order was wrong after moving back: %v", om.GetOrder())
+	}
+
+	if err := om.Insert(om.Count(), "two", TestData{ID: 2, Name: "two"}); err == nil {
+		t.Error("No error was received when moving an existing key past the end.")
+	}
+	if err := om.Insert(om.Count()-1, "two", TestData{ID: 2, Name: "two"}); err != nil {
+		t.Error("Error trying to move an existing key to the end: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "four,three,one,two" {
+		t.Errorf("Order was wrong after moving to the end: %v", om.GetOrder())
+	}
+}
+
+func TestAddTuple(t *testing.T) {
+	src := New()
+	src.Add("one", TestData{ID: 1, Name: "one"})
+	src.Add("two", TestData{ID: 2, Name: "two"})
+	src.Add("three", TestData{ID: 3, Name: "three"})
+
+	dst := New()
+	itr := src.Iterator()
+	for item := range itr.Loop() {
+		dst.AddTuple(item)
+	}
+
+	if dst.Count() != 3 {
+		t.Error("Map does not contain three items")
+	}
+
+	ord := dst.GetOrder()
+	if ord[0] != "one" || ord[1] != "two" || ord[2] != "three" {
+		t.Errorf("Order was not preserved: %v", ord)
+	}
+
+	test, _ := dst.GetKey("two")
+	if test.(TestData).ID != 2 {
+		t.Error("Wrong item was returned from map")
+	}
+}
+
+func TestInsertTuple(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	err := om.InsertTuple(1, Tuple{"two", TestData{ID: 2, Name: "two"}})
+	if err != nil {
+		t.Error("Error trying to insert into ordered map: " + err.Error())
+	}
+
+	if key, _, _ := om.GetIndex(1); key != "two" {
+		t.Error("Index two is not the correct object name")
+	}
+
+	err = om.InsertTuple(30, Tuple{"six", TestData{ID: 6, Name: "six"}})
+	if err == nil {
+		t.Error("No error was received when trying to insert above the range.")
+	}
+}
+
+func TestInsertSortedFunc(t *testing.T) {
+	byID := func(aKey string, aVal interface{}, bKey string, bVal interface{}) bool {
+		return aVal.(TestData).ID < bVal.(TestData).ID
+	}
+
+	om := New()
+	for _, i := range []int{5, 1, 4, 2, 3} {
+		str := strconv.Itoa(i)
+		om.InsertSortedFunc(str, TestData{ID: i, Name: str}, byID)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "1,2,3,4,5" {
+		t.Errorf("Order was not sorted: %s", got)
+	}
+
+	om.InsertSortedFunc("1", TestData{ID: 10, Name: "1"}, byID)
+	if got := strings.Join(om.GetOrder(), ","); got != "2,3,4,5,1" {
+		t.Errorf("Updated key was not moved to its sorted position: %s", got)
+	}
+	if om.Count() != 5 {
+		t.Error("Size of ordered map was wrong")
+	}
+}
+
+func TestInsertAll(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("four", 4)
+
+	err := om.InsertAll(1, []Tuple{{"two", 2}, {"three", 3}})
+	if err != nil {
+		t.Error("Error trying to insert into ordered map: " + err.Error())
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "one,two,three,four" {
+		t.Errorf("Order was wrong after splice: %s", got)
+	}
+	if val, _ := om.GetKey("three"); val.(int) != 3 {
+		t.Error("Wrong item was returned from map")
+	}
+
+	err = om.InsertAll(om.Count(), []Tuple{{"five", 5}})
+	if err != nil {
+		t.Error("Error trying to insert at the end of the map: " + err.Error())
+	}
+
+	err = om.InsertAll(0, []Tuple{{"six", 6}, {"six", 6}})
+	if err == nil {
+		t.Error("No error was received when inserting duplicate keys in the batch.")
+	}
+	err = om.InsertAll(0, []Tuple{{"six", 6}, {"two", 2}})
+	if err == nil {
+		t.Error("No error was received when inserting a key that already exists.")
+	}
+	err = om.InsertAll(30, []Tuple{{"six", 6}})
+	if err == nil {
+		t.Error("No error was received when trying to insert above the range.")
+	}
+	if om.Count() != 5 || om.IndexOf("six") != -1 {
+		t.Error("Map was changed by a failed insert")
+	}
+}
+
+func TestGetKey(t *testing.T) {
+	om := New()
+
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	test, ok := om.GetKey("two")
+	gotten := test.(TestData)
+
+	if !ok {
+		t.Error("Unable to get item from map by key")
+	}
+
+	if gotten.ID != 2 || gotten.Name != "two" {
+		t.Error("Wrong item was returned from map")
+	}
+}
+
+func TestGetOrDefault(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("nil", nil)
+
+	if val := om.GetOrDefault("one", "fallback"); val.(TestData).ID != 1 {
+		t.Error("GetOrDefault did not return the stored value")
+	}
+	if val := om.GetOrDefault("two", "fallback"); val != "fallback" {
+		t.Error("GetOrDefault did not return the default for a missing key")
+	}
+	if val := om.GetOrDefault("nil", "fallback"); val != nil {
+		t.Error("GetOrDefault returned the default for a key holding nil")
+	}
+}
+
+func TestGetCopy(t *testing.T) {
+	om := New()
+	om.Add("list", []int{1, 2, 3})
+
+	dup := func(v interface{}) interface{} {
+		src := v.([]int)
+		tmp := make([]int, len(src))
+		copy(tmp, src)
+		return tmp
+	}
+
+	val, ok := om.GetCopy("list", dup)
+	if !ok {
+		t.Fatal("Unable to get item from map by key")
+	}
+	val.([]int)[0] = 100
+
+	stored, _ := om.GetKey("list")
+	if stored.([]int)[0] != 1 {
+		t.Error("Changing the copy changed the stored value")
+	}
+
+	if _, ok := om.GetCopy("missing", dup); ok {
+		t.Error("Missing key was returned from map")
+	}
+
+	raw, _ := om.GetCopy("list", nil)
+	raw.([]int)[0] = 100
+	if stored.([]int)[0] != 100 {
+		t.Error("Nil copy function did not return the stored value")
+	}
+}
+
+func TestGetKeyWithIndex(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	val, idx, ok := om.GetKeyWithIndex("two")
+	if !ok {
+		t.Error("Unable to get item from map by key")
+	}
+	if idx != 1 || val.(TestData).ID != 2 {
+		t.Error("Wrong item was returned from map")
+	}
+
+	val, idx, ok = om.GetKeyWithIndex("four")
+	if ok || idx != -1 || val != nil {
+		t.Error("Missing key was returned from map")
+	}
+}
+
+func TestGetOrCompute(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+
+	val := om.GetOrCompute("one", func() interface{} {
+		t.Error("Compute was called for an existing key")
+		return 0
+	})
+	if val.(int) != 1 {
+		t.Error("Wrong item was returned from map")
+	}
+
+	calls := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val := om.GetOrCompute("two", func() interface{} {
+				calls++
+				return 2
+			})
+			if val.(int) != 2 {
+				t.Error("Wrong item was returned from map")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Compute was called %d times, expected 1", calls)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "one,two" {
+		t.Errorf("Order was wrong after compute: %s", got)
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+
+	val, loaded := om.GetOrSet("one", TestData{ID: 10, Name: "ten"})
+	if !loaded || val.(TestData).ID != 1 {
+		t.Error("GetOrSet did not return the existing value")
+	}
+	val, loaded = om.GetOrSet("two", TestData{ID: 2, Name: "two"})
+	if loaded || val.(TestData).ID != 2 {
+		t.Error("GetOrSet did not return the new value")
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,two" {
+		t.Errorf("Order was wrong after GetOrSet: %v", om.GetOrder())
+	}
+
+	var wg sync.WaitGroup
+	var cnt int64
+	var mu sync.Mutex
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, loaded := om.GetOrSet("three", i); !loaded {
+				mu.Lock()
+				cnt++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if cnt != 1 || om.Count() != 3 {
+		t.Error("GetOrSet stored the same key more than once")
+	}
+}
+
+func TestHas(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+
+	if !om.Has("one") {
+		t.Error("Existing key was not found")
+	}
+	if om.Has("two") {
+		t.Error("Missing key was found")
+	}
+}
+
+func TestHasAllAny(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	present := []string{"one", "three"}
+	partial := []string{"two", "four"}
+	absent := []string{"four", "five"}
+
+	if !om.HasAll(present) || !om.HasAny(present) {
+		t.Error("Fully present keys were not found")
+	}
+	if om.HasAll(partial) || !om.HasAny(partial) {
+		t.Error("Partially present keys gave the wrong result")
+	}
+	if om.HasAll(absent) || om.HasAny(absent) {
+		t.Error("Fully absent keys were found")
+	}
+}
+
+func TestGetIndex(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	key, val, ok := om.GetIndex(1)
+	gotten := val.(TestData)
+
+	if !ok {
+		t.Error("Unable to get item from map by index")
+	}
+
+	if key != "two" || gotten.ID != 2 || gotten.Name != "two" {
+		t.Error("Wrong item was returned from map")
+	}
+
+	if key, val, ok := om.GetIndex(3); ok || key != "" || val != nil {
+		t.Error("Index above the range returned an item")
+	}
+	if _, _, ok := om.GetIndex(-1); ok {
+		t.Error("Negative index returned an item")
+	}
+}
+
+func TestValueAtFraction(t *testing.T) {
+	odd := New()
+	for i := 1; i <= 5; i++ {
+		odd.Add(strconv.Itoa(i), i)
+	}
+	even := New()
+	for i := 1; i <= 4; i++ {
+		even.Add(strconv.Itoa(i), i)
+	}
+
+	tests := []struct {
+		om       *OrderedMap
+		fraction float64
+		key      string
+	}{
+		{odd, 0, "1"},
+		{odd, 0.5, "3"},
+		{odd, 1, "5"},
+		{even, 0, "1"},
+		{even, 0.5, "2"},
+		{even, 1, "4"},
+	}
+
+	for _, test := range tests {
+		key, val, ok := test.om.ValueAtFraction(test.fraction)
+		if !ok || key != test.key || strconv.Itoa(val.(int)) != test.key {
+			t.Errorf("Fraction %v of %d items returned %s", test.fraction, test.om.Count(), key)
+		}
+	}
+
+	if _, _, ok := odd.ValueAtFraction(1.5); ok {
+		t.Error("Fraction above 1 returned an item")
+	}
+	empty := New()
+	if _, _, ok := empty.ValueAtFraction(0.5); ok {
+		t.Error("Empty map returned an item")
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	ord := om.GetOrder()
+	if ord[0] != "one" {
+		t.Error("First item was wrong")
+	} else if ord[1] != "two" {
+		t.Error("Second item was wrong")
+	} else if ord[2] != "three" {
+		t.Error("Third item was wrong")
+	}
+}
+
+func TestValues(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	vals := om.Values()
+	if len(vals) != 3 {
+		t.Fatal("Wrong number of values were returned")
+	}
+	for i, v := range vals {
+		if v.(TestData).ID != i+1 {
+			t.Errorf("Value %d was wrong", i)
+		}
+	}
+
+	vals[0] = TestData{ID: 100}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 1 {
+		t.Error("Changing the returned slice changed the map")
+	}
+}
+
+func TestValuePtrs(t *testing.T) {
+	om := New()
+	om.Add("one", &TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	vals := om.ValuePtrs()
+	if len(vals) != 2 {
+		t.Fatal("Wrong number of values were returned")
+	}
+
+	vals[0].(*TestData).Name = "uno"
+	if val, _ := om.GetKey("one"); val.(*TestData).Name != "uno" {
+		t.Error("Change through a pointer value was not reflected in the map")
+	}
+
+	copied := vals[1].(TestData)
+	copied.Name = "dos"
+	if val, _ := om.GetKey("two"); val.(TestData).Name != "two" {
+		t.Error("Change to a plain value was reflected in the map")
+	}
+}
+
+func TestSetOrder(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	err := om.SetOrder([]string{"three", "one", "two"})
+	if err != nil {
+		t.Error("An error occured setting order: " + err.Error())
+	}
+
+	ord := om.GetOrder()
+	if ord[0] != "three" {
+		t.Error("First item was wrong")
+	} else if ord[1] != "one" {
+		t.Error("Second item was wrong")
+	} else if ord[2] != "two" {
+		t.Error("Third item was wrong")
+	}
+
+	err = om.SetOrder([]string{"three", "one", "two", "five", "eleventy"})
+	if err == nil {
+		t.Error("No error occured when trying to use an order that was too large")
+	}
+
+	err = om.SetOrder([]string{"three", "one", "five"})
+	if err == nil {
+		t.Error("No error occured when trying to use an order the right size, but with the wrong items")
+	}
+}
+
+func TestReorderByReference(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+	om.Add("four", 4)
+	om.Add("five", 5)
+
+	om.ReorderByReference([]string{"four", "six", "two", "seven"})
+	if got := strings.Join(om.GetOrder(), ","); got != "four,two,one,three,five" {
+		t.Errorf("Order was wrong after reorder: %s", got)
+	}
+	if om.Count() != 5 {
+		t.Error("Size of ordered map was wrong")
+	}
+
+	om.ReorderByReference([]string{})
+	if got := strings.Join(om.GetOrder(), ","); got != "four,two,one,three,five" {
+		t.Errorf("Empty reference changed the order: %s", got)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	om.OrderBy(func(aKey string, aVal interface{}, bKey string, bVal interface{}) bool {
+		return aVal.(TestData).ID > bVal.(TestData).ID
+	})
+
+	ord := om.GetOrder()
+	if ord[0] != "three" || ord[1] != "two" || ord[2] != "one" {
+		t.Errorf("Order was wrong after OrderBy: %v", ord)
+	}
+
+	om.OrderBy(func(aKey string, aVal interface{}, bKey string, bVal interface{}) bool {
+		return aKey < bKey
+	})
+
+	ord = om.GetOrder()
+	if ord[0] != "one" || ord[1] != "three" || ord[2] != "two" {
+		t.Errorf("Order was wrong after OrderBy on keys: %v", ord)
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	om := New()
+	om.Add("delta", TestData{ID: 4, Name: "delta"})
+	om.Add("alpha", TestData{ID: 1, Name: "alpha"})
+	om.Add("charlie", TestData{ID: 3, Name: "charlie"})
+	om.Add("bravo", TestData{ID: 2, Name: "bravo"})
+
+	om.SortKeys()
+	if strings.Join(om.GetOrder(), ",") != "alpha,bravo,charlie,delta" {
+		t.Errorf("Keys were not sorted: %v", om.GetOrder())
+	}
+	if val, _ := om.GetKey("charlie"); val.(TestData).ID != 3 {
+		t.Error("Sorting changed a value")
+	}
+	if om.IndexOf("delta") != 3 {
+		t.Error("Index of delta was not 3")
+	}
+}
+
+func TestSortFunc(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 3, Name: "one"})
+	om.Add("two", TestData{ID: 1, Name: "two"})
+	om.Add("three", TestData{ID: 2, Name: "three"})
+
+	om.SortFunc(func(a, b Tuple) bool {
+		return a.Val.(TestData).ID < b.Val.(TestData).ID
+	})
+	if strings.Join(om.GetOrder(), ",") != "two,three,one" {
+		t.Errorf("Map was not sorted by value: %v", om.GetOrder())
+	}
+}
+
+func TestSortByKeyFunc(t *testing.T) {
+	om := New()
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	om.SortByKeyFunc(func(a, b string) bool {
+		return len(a) < len(b)
+	})
+	if strings.Join(om.GetOrder(), ",") != "one,two,four,three" {
+		t.Errorf("Map was not sorted by key length: %v", om.GetOrder())
+	}
+	if om.IndexOf("four") != 2 {
+		t.Error("Index of four was not 2")
+	}
+	if val, _ := om.GetKey("four"); val.(TestData).ID != 4 {
+		t.Error("Sorting changed a value")
+	}
+}
+
+func TestMoveToFront(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	if err := om.MoveToFront("two"); err != nil {
+		t.Error("Error moving key to the front: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "two,one,three" {
+		t.Errorf("Order was wrong after moving to the front: %v", om.GetOrder())
+	}
+	if val, _ := om.GetKey("two"); val.(TestData).ID != 2 {
+		t.Error("Moving a key changed its value")
+	}
+	if err := om.MoveToFront("four"); err == nil {
+		t.Error("No error was received when moving a missing key.")
+	}
+}
+
+func TestMoveToBack(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	if err := om.MoveToBack("two"); err != nil {
+		t.Error("Error moving key to the back: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,three,two" {
+		t.Errorf("Order was wrong after moving to the back: %v", om.GetOrder())
+	}
+	if om.IndexOf("two") != 2 {
+		t.Error("Index of two was not 2")
+	}
+	if err := om.MoveToBack("four"); err == nil {
+		t.Error("No error was received when moving a missing key.")
+	}
+}
+
+func TestMove(t *testing.T) {
+	om := New()
+	om.Add("zero", TestData{ID: 0, Name: "zero"})
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+
+	if err := om.Move("four", 1); err != nil {
+		t.Error("Error moving key backward: " + err.Error())
+	}
+	expected := []string{"zero", "four", "one", "two", "three"}
+	for i, k := range expected {
+		if om.IndexOf(k) != i {
+			t.Errorf("Index of %s was %d, expected %d", k, om.IndexOf(k), i)
+		}
+	}
+
+	if err := om.Move("zero", 3); err != nil {
+		t.Error("Error moving key forward: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "four,one,two,zero,three" {
+		t.Errorf("Order was wrong after moving forward: %v", om.GetOrder())
+	}
+
+	if err := om.Move("one", 5); err == nil {
+		t.Error("No error was received when moving above the range.")
+	}
+	if err := om.Move("one", -1); err == nil {
+		t.Error("No error was received when moving to a negative position.")
+	}
+	if err := om.Move("five", 0); err == nil {
+		t.Error("No error was received when moving a missing key.")
+	}
+}
+
+func TestReverse(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+	om.Add("five", TestData{ID: 5, Name: "five"})
+
+	om.Reverse()
+	if strings.Join(om.GetOrder(), ",") != "five,four,three,two,one" {
+		t.Errorf("Map was not reversed: %v", om.GetOrder())
+	}
+	if key, val, _ := om.GetIndex(0); key != "five" || val.(TestData).ID != 5 {
+		t.Error("First item after reversing was not the old last item")
+	}
+	if om.IndexOf("one") != 4 {
+		t.Error("Index of one was not 4")
+	}
+}
+
+func TestReverseRange(t *testing.T) {
+	om := New()
+	for i := 1; i <= 5; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	if err := om.ReverseRange(1, 4); err != nil {
+		t.Error("Error reversing range: " + err.Error())
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "1,4,3,2,5" {
+		t.Errorf("Order was wrong after reversing middle: %s", got)
+	}
+
+	if err := om.ReverseRange(0, om.Count()); err != nil {
+		t.Error("Error reversing range: " + err.Error())
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "5,2,3,4,1" {
+		t.Errorf("Order was wrong after reversing everything: %s", got)
+	}
+
+	if err := om.ReverseRange(-1, 2); err == nil {
+		t.Error("No error was received for a negative start.")
+	}
+	if err := om.ReverseRange(0, 6); err == nil {
+		t.Error("No error was received for an end above the range.")
+	}
+	if err := om.ReverseRange(3, 2); err == nil {
+		t.Error("No error was received for a start after the end.")
+	}
+}
+
+func TestStablePartition(t *testing.T) {
+	om := New()
+	for i := 1; i <= 8; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	om.StablePartition(func(key string, value interface{}) bool {
+		return value.(int)%3 == 0
+	})
+
+	if got := strings.Join(om.GetOrder(), ","); got != "3,6,1,2,4,5,7,8" {
+		t.Errorf("Order was wrong after partition: %s", got)
+	}
+	if om.Count() != 8 {
+		t.Error("Size of ordered map was wrong")
+	}
+}
+
+func TestMoveMatchingToBack(t *testing.T) {
+	om := New()
+	for i := 1; i <= 8; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	om.MoveMatchingToBack(func(key string, value interface{}) bool {
+		return value.(int)%3 == 0
+	})
+
+	if got := strings.Join(om.GetOrder(), ","); got != "1,2,4,5,7,8,3,6" {
+		t.Errorf("Order was wrong after moving matches: %s", got)
+	}
+	if om.Count() != 8 {
+		t.Error("Size of ordered map was wrong")
+	}
+}
+
+func TestShuffleSeeded(t *testing.T) {
+	shuffled := func(seed int64) string {
+		om := New()
+		for i := 0; i < 20; i++ {
+			om.Add(strconv.Itoa(i), i)
+		}
+		om.ShuffleSeeded(seed)
+		if om.Count() != 20 {
+			t.Error("Size of ordered map was wrong")
+		}
+		return strings.Join(om.GetOrder(), ",")
+	}
+
+	first := shuffled(42)
+	if second := shuffled(42); first != second {
+		t.Errorf("Same seed gave different orders: %s != %s", first, second)
+	}
+	if other := shuffled(7); first == other {
+		t.Errorf("Different seeds gave the same order: %s", first)
+	}
+}
+
+func TestReindexByValueField(t *testing.T) {
+	om := New()
+	om.Add("b", TestData{ID: 3, Name: "bravo"})
+	om.Add("c", &TestData{ID: 1, Name: "charlie"})
+	om.Add("a", TestData{ID: 2, Name: "alpha"})
+
+	err := om.ReindexByValueField("ID")
+	if err != nil {
+		t.Error("Error sorting by field: " + err.Error())
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "c,a,b" {
+		t.Errorf("Order was wrong after sorting by ID: %s", got)
+	}
+
+	err = om.ReindexByValueField("Name")
+	if err != nil {
+		t.Error("Error sorting by field: " + err.Error())
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "a,b,c" {
+		t.Errorf("Order was wrong after sorting by Name: %s", got)
+	}
+
+	if err := om.ReindexByValueField("Missing"); err == nil {
+		t.Error("No error was received when sorting by a missing field.")
+	}
+
+	om.Add("d", 4)
+	if err := om.ReindexByValueField("ID"); err == nil {
+		t.Error("No error was received when sorting a value that is not a struct.")
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "a,b,c,d" {
+		t.Errorf("Order was changed by a failed sort: %s", got)
+	}
+
+	type Inner struct{ ID int }
+	type Outer struct{ *Inner }
+	embedded := New()
+	embedded.Add("a", Outer{&Inner{ID: 2}})
+	embedded.Add("b", Outer{})
+	if err := embedded.ReindexByValueField("ID"); err == nil {
+		t.Error("No error was received when sorting through a nil embedded pointer.")
+	}
+	embedded.Set("b", Outer{&Inner{ID: 1}})
+	if err := embedded.ReindexByValueField("ID"); err != nil {
+		t.Error("Error sorting through an embedded pointer: " + err.Error())
+	}
+	if got := strings.Join(embedded.GetOrder(), ","); got != "b,a" {
+		t.Errorf("Embedded field was not sorted: %s", got)
+	}
+}
+
+func TestSortedTuples(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	res := om.SortedTuples(func(a, b Tuple) bool {
+		return a.Val.(TestData).ID > b.Val.(TestData).ID
+	})
+	if len(res) != 3 || res[0].Key != "three" || res[1].Key != "two" || res[2].Key != "one" {
+		t.Errorf("Tuples were not sorted: %v", res)
+	}
+
+	if got := strings.Join(om.GetOrder(), ","); got != "one,three,two" {
+		t.Errorf("Map order was changed by sorting tuples: %s", got)
+	}
+}
+
+func TestInsertionOrder(t *testing.T) {
+	om := NewWithDisplayOrder()
+	om.Add("charlie", 3)
+	om.Add("alpha", 1)
+	om.Add("bravo", 2)
+	om.Insert(0, "delta", 4)
+
+	err := om.SetOrder([]string{"alpha", "bravo", "charlie", "delta"})
+	if err != nil {
+		t.Error("An error occured setting order: " + err.Error())
+	}
+
+	ord := om.GetOrder()
+	if ord[0] != "alpha" || ord[1] != "bravo" || ord[2] != "charlie" || ord[3] != "delta" {
+		t.Errorf("Display order was wrong: %v", ord)
+	}
+
+	ins := om.InsertionOrder()
+	if len(ins) != 4 || ins[0] != "charlie" || ins[1] != "alpha" || ins[2] != "bravo" || ins[3] != "delta" {
+		t.Errorf("Insertion order was changed by sorting: %v", ins)
+	}
+
+	om.Delete("alpha")
+	ins = om.InsertionOrder()
+	if len(ins) != 3 || ins[0] != "charlie" || ins[1] != "bravo" || ins[2] != "delta" {
+		t.Errorf("Insertion order was wrong after delete: %v", ins)
+	}
+
+	plain := New()
+	plain.Add("one", 1)
+	if plain.InsertionOrder() != nil {
+		t.Error("Insertion order was tracked on a plain map")
+	}
+}
+
+func TestAddedAt(t *testing.T) {
+	om := NewTimestamped()
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	om.now = func() time.Time { return clock }
+
+	om.Add("one", 1)
+	clock = clock.Add(time.Minute)
+	om.Add("two", 2)
+	clock = clock.Add(time.Minute)
+
+	if at, ok := om.AddedAt("one"); !ok || !at.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Time for one was wrong: %v", at)
+	}
+	if at, ok := om.AddedAt("two"); !ok || !at.Equal(time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)) {
+		t.Errorf("Time for two was wrong: %v", at)
+	}
+
+	om.ReplaceValue(1, 10, nil)
+	om.AddValidated("two", 20, func(interface{}) error { return nil })
+	if at, _ := om.AddedAt("one"); !at.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Time was changed by updating a value")
+	}
+	if at, _ := om.AddedAt("two"); !at.Equal(time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)) {
+		t.Error("Time was changed by updating a value")
+	}
+
+	om.Delete("one")
+	if _, ok := om.AddedAt("one"); ok {
+		t.Error("Time for a deleted key still exists")
+	}
+
+	plain := New()
+	plain.Add("one", 1)
+	if _, ok := plain.AddedAt("one"); ok {
+		t.Error("Time was tracked on a plain map")
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	idx := om.IndexOf("one")
+	if idx != 0 {
+		t.Error("Index of one was not 0")
+	}
+	idx = om.IndexOf("three")
+	if idx != 2 {
+		t.Error("Index of three was not 2")
+	}
+}
+
+func TestIndexOfDuplicate(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("one", TestData{ID: 1, Name: "one"})
+
+	if om.IndexOf("one") != 0 {
+		t.Error("Index of a repeated key was not its first position")
+	}
+	if om.IndexOf("missing") != -1 {
+		t.Error("Index of a missing key was not -1")
+	}
+}
+
+func TestIndexOfAfterChanges(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+
+	om.Delete("two")
+	om.Insert(1, "five", TestData{ID: 5, Name: "five"})
+	om.SetOrder([]string{"four", "five", "one", "three"})
+	om.AddOrMoveToBack("five", TestData{ID: 5, Name: "five"})
+
+	for i, k := range om.GetOrder() {
+		if om.IndexOf(k) != i {
+			t.Errorf("Index of %s was %d, expected %d", k, om.IndexOf(k), i)
+		}
+	}
+	if om.IndexOf("two") != -1 {
+		t.Error("Index of deleted key was not -1")
+	}
+}
+
+func TestIndexAfterRemovals(t *testing.T) {
+	om := New()
+	for i := 0; i < 20; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	check := func(step string) {
+		for i, k := range om.GetOrder() {
+			if om.IndexOf(k) != i {
+				t.Errorf("After %s, index of %s was %d, expected %d", step, k, om.IndexOf(k), i)
+			}
+		}
+		for k, i := range om.IndexMap() {
+			if key, _, _ := om.GetIndex(i); key != k {
+				t.Errorf("After %s, index map had %s at %d", step, k, i)
+			}
+		}
+	}
+
+	om.PopFront()
+	om.PopFront()
+	check("popping the front")
+	om.Delete("5")
+	check("deleting near the front")
+	om.Delete("15")
+	check("deleting near the back")
+	om.PopBack()
+	check("popping the back")
+	om.Insert(1, "new", 0)
+	check("inserting")
+	om.Swap("2", "18")
+	om.Rename("3", "three")
+	om.MoveToFront("10")
+	check("moving")
+	om.Add("20", 20)
+	om.PopFront()
+	check("adding and popping")
+	if om.IndexOf("0") != -1 || om.IndexOf("5") != -1 {
+		t.Error("Index of a removed key was not -1")
+	}
+}
+
+func TestIndexMap(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	idx := om.IndexMap()
+	ord := om.GetOrder()
+	if len(idx) != len(ord) {
+		t.Error("Index map was the wrong size")
+	}
+	for i, k := range ord {
+		if idx[k] != i {
+			t.Errorf("Index of %s was %d, expected %d", k, idx[k], i)
+		}
+	}
+
+	om.SetOrder([]string{"three", "two", "one"})
+	if idx["one"] != 0 || idx["three"] != 2 {
+		t.Error("Index map was changed by reordering")
+	}
+}
+
+func TestSearchSortedIndex(t *testing.T) {
+	om := New()
+	om.Add("apple", 1)
+	om.Add("cherry", 3)
+	om.Add("elderberry", 5)
+
+	if idx := om.SearchSortedIndex("banana"); idx != 1 {
+		t.Errorf("Index of banana was %d, expected 1", idx)
+	}
+	if idx := om.SearchSortedIndex("cherry"); idx != 1 {
+		t.Errorf("Index of existing key was %d, expected 1", idx)
+	}
+	if idx := om.SearchSortedIndex("aardvark"); idx != 0 {
+		t.Errorf("Index of aardvark was %d, expected 0", idx)
+	}
+	if idx := om.SearchSortedIndex("fig"); idx != 3 {
+		t.Errorf("Index of fig was %d, expected 3", idx)
+	}
+}
+
+func TestCeilingFloorKey(t *testing.T) {
+	om := New()
+	om.Add("b", 1)
+	om.Add("d", 2)
+	om.Add("f", 3)
+
+	tests := []struct {
+		key     string
+		ceiling string
+		cok     bool
+		floor   string
+		fok     bool
+	}{
+		{"d", "d", true, "d", true},
+		{"c", "d", true, "b", true},
+		{"a", "b", true, "", false},
+		{"g", "", false, "f", true},
+	}
+
+	for _, test := range tests {
+		if key, ok := om.CeilingKey(test.key); key != test.ceiling || ok != test.cok {
+			t.Errorf("CeilingKey(%s) returned %s, %v", test.key, key, ok)
+		}
+		if key, ok := om.FloorKey(test.key); key != test.floor || ok != test.fok {
+			t.Errorf("FloorKey(%s) returned %s, %v", test.key, key, ok)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	om.Delete("two")
+	_, ok := om.GetKey("two")
+	if ok {
+		t.Error("Deleted key still exists")
+	}
+	if om.Count() != 2 {
+		t.Error("Size of ordered map was wrong")
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	om.Delete("three")
+	if om.Count() != 2 {
+		t.Error("Size of ordered map was wrong")
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,two" {
+		t.Errorf("Order was changed by deleting a missing key: %v", om.GetOrder())
+	}
+}
+
+func TestDeleteIndex(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+	om.Add("five", TestData{ID: 5, Name: "five"})
+
+	if err := om.DeleteIndex(0); err != nil {
+		t.Error("Error deleting the first index: " + err.Error())
+	}
+	if err := om.DeleteIndex(1); err != nil {
+		t.Error("Error deleting a middle index: " + err.Error())
+	}
+	if err := om.DeleteIndex(om.Count() - 1); err != nil {
+		t.Error("Error deleting the last index: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "two,four" {
+		t.Errorf("Order was wrong after deleting: %v", om.GetOrder())
+	}
+	if om.Has("one") || om.Has("three") || om.Has("five") {
+		t.Error("Deleted key still exists")
+	}
+
+	if err := om.DeleteIndex(2); err == nil {
+		t.Error("No error was received when deleting above the range.")
+	}
+	if err := om.DeleteIndex(-1); err == nil {
+		t.Error("No error was received when deleting a negative index.")
+	}
+	if om.Count() != 2 {
+		t.Error("Size of ordered map was wrong")
+	}
+}
+
+func TestPopFront(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	keys := make([]string, 0)
+	for {
+		key, val, ok := om.PopFront()
+		if !ok {
+			break
+		}
+		if val.(TestData).Name != key {
+			t.Errorf("Value for %s was wrong", key)
+		}
+		keys = append(keys, key)
+	}
+	if strings.Join(keys, ",") != "one,two,three" {
+		t.Errorf("Items were not popped in FIFO order: %v", keys)
+	}
+	if om.Count() != 0 {
+		t.Error("Map was not empty after popping every item")
+	}
+}
+
+func TestPopBack(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	key, val, ok := om.PopBack()
+	if !ok || key != "two" || val.(TestData).ID != 2 {
+		t.Error("PopBack did not return the last item")
+	}
+	if om.Has("two") || om.Count() != 1 {
+		t.Error("PopBack did not remove the last item")
+	}
+	om.PopBack()
+	if _, _, ok := om.PopBack(); ok {
+		t.Error("PopBack returned an item from an empty map")
+	}
+}
+
+func TestTrimFunc(t *testing.T) {
+	om := New()
+	om.Add("a", "")
+	om.Add("b", "")
+	om.Add("c", "first")
+	om.Add("d", "")
+	om.Add("e", "last")
+	om.Add("f", "")
+
+	empty := func(key string, value interface{}) bool {
+		return value.(string) == ""
+	}
+
+	om.TrimFunc(empty)
+	if got := strings.Join(om.GetOrder(), ","); got != "c,d,e" {
+		t.Errorf("Order was wrong after trim: %s", got)
+	}
+	if om.Count() != 3 {
+		t.Error("Size of ordered map was wrong")
+	}
+	if _, ok := om.GetKey("a"); ok {
+		t.Error("Trimmed key still exists")
+	}
+	if _, ok := om.GetKey("d"); !ok {
+		t.Error("Interior key was removed")
+	}
+
+	om.TrimFunc(func(key string, value interface{}) bool { return true })
+	if om.Count() != 0 || len(om.GetOrder()) != 0 {
+		t.Error("Map was not empty after trimming everything")
+	}
+}
+
+func TestCut(t *testing.T) {
+	om := New()
+	for i := 1; i <= 6; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	items, err := om.Cut(2, 4)
+	if err != nil {
+		t.Error("Error cutting range: " + err.Error())
+	}
+	if len(items) != 2 || items[0].Key != "3" || items[1].Key != "4" || items[1].Val.(int) != 4 {
+		t.Errorf("Cut returned the wrong items: %v", items)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "1,2,5,6" {
+		t.Errorf("Order was wrong after cutting the middle: %s", got)
+	}
+	if _, ok := om.GetKey("3"); ok {
+		t.Error("Cut key still exists")
+	}
+
+	items, _ = om.Cut(0, 1)
+	if len(items) != 1 || items[0].Key != "1" {
+		t.Errorf("Cut returned the wrong items from the front: %v", items)
+	}
+	items, _ = om.Cut(om.Count()-1, om.Count())
+	if len(items) != 1 || items[0].Key != "6" {
+		t.Errorf("Cut returned the wrong items from the back: %v", items)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "2,5" {
+		t.Errorf("Order was wrong after cutting the ends: %s", got)
+	}
+
+	if _, err := om.Cut(1, 5); err == nil {
+		t.Error("No error was received for an end above the range.")
+	}
+	if _, err := om.Cut(2, 1); err == nil {
+		t.Error("No error was received for a start after the end.")
+	}
+	if om.Count() != 2 {
+		t.Error("Map was changed by a failed cut")
+	}
+}
+
+func TestEvictOlderThan(t *testing.T) {
+	om := NewTimestamped()
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	om.now = func() time.Time { return clock }
+
+	for i := 1; i <= 5; i++ {
+		om.Add(strconv.Itoa(i), i)
+		clock = clock.Add(time.Minute)
+	}
+
+	cnt := om.EvictOlderThan(150 * time.Second)
+	if cnt != 3 {
+		t.Errorf("Evicted %d items, expected 3", cnt)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "4,5" {
+		t.Errorf("Wrong items survived eviction: %s", got)
+	}
+	if om.Count() != 2 {
+		t.Error("Size of ordered map was wrong")
+	}
+
+	plain := New()
+	plain.Add("one", 1)
+	if plain.EvictOlderThan(0) != 0 || plain.Count() != 1 {
+		t.Error("Eviction did something on a plain map")
+	}
+}
+
+func TestGC(t *testing.T) {
+	om := New()
+	for i := 0; i < 1000; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+	for i := 0; i < 1000; i++ {
+		if i%100 != 0 {
+			om.Delete(strconv.Itoa(i))
+		}
+	}
+
+	om.GC()
+
+	if om.Count() != 10 {
+		t.Errorf("Map contains %d items, expected 10", om.Count())
+	}
+	for i, k := range om.GetOrder() {
+		if k != strconv.Itoa(i*100) {
+			t.Errorf("Index %d was %s", i, k)
+		}
+		if val, ok := om.GetKey(k); !ok || val.(TestData).ID != i*100 {
+			t.Errorf("Wrong item was returned for %s", k)
+		}
+	}
+}
+
+func TestGCDeletedDuplicate(t *testing.T) {
+	om := New()
+	om.Add("a", 1)
+	om.Add("a", 2)
+	om.Add("b", 3)
+	om.Delete("a")
+
+	om.GC()
+	if om.Count() != 1 || om.Has("a") {
+		t.Error("GC brought back a deleted key")
+	}
+	if strings.Join(om.GetOrder(), ",") != "b" {
+		t.Errorf("Order was wrong after GC: %v", om.GetOrder())
+	}
+	if om.IndexOf("b") != 0 {
+		t.Error("Index of b was not 0 after GC")
+	}
+}
+
+func TestCount(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	if om.Count() != 1 {
+		t.Error("First count was wrong")
+	}
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	if om.Count() != 2 {
+		t.Error("Second count was wrong")
+	}
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	if om.Count() != 3 {
+		t.Error("Third count was wrong")
+	}
+}
+
+func TestString(t *testing.T) {
+	om := New()
+	if om.String() != "OrderedMap{}" {
+		t.Errorf("Empty map was printed as %s", om.String())
+	}
+
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	if s := fmt.Sprint(om); s != "OrderedMap{one:1, two:2, three:{3 three}}" {
+		t.Errorf("Map was printed as %s", s)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	keys := make([]string, 0)
+	om.ForEach(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		om.Set(key, value.(TestData).ID*10)
+		return true
+	})
+	if strings.Join(keys, ",") != "one,two,three" {
+		t.Errorf("ForEach visited the wrong keys: %v", keys)
+	}
+	if val, _ := om.GetKey("three"); val != 30 {
+		t.Error("ForEach could not change the map from inside fn")
+	}
+
+	cnt := 0
+	om.ForEach(func(key string, value interface{}) bool {
+		cnt++
+		return key != "two"
+	})
+	if cnt != 2 {
+		t.Error("ForEach did not stop when fn returned false")
+	}
+}
+
+func TestRangePrefix(t *testing.T) {
+	om := New()
+	om.Add("user:2", 2)
+	om.Add("group:1", 10)
+	om.Add("user:1", 1)
+	om.Add("group:2", 20)
+	om.Add("user:3", 3)
+
+	visited := make([]string, 0)
+	om.RangePrefix("user:", func(key string, value interface{}) bool {
+		visited = append(visited, key)
+		return true
+	})
+	if got := strings.Join(visited, ","); got != "user:2,user:1,user:3" {
+		t.Errorf("Wrong keys were visited: %s", got)
+	}
+
+	visited = visited[:0]
+	om.RangePrefix("user:", func(key string, value interface{}) bool {
+		visited = append(visited, key)
+		return value.(int) != 1
+	})
+	if got := strings.Join(visited, ","); got != "user:2,user:1" {
+		t.Errorf("Range did not stop early: %s", got)
+	}
+}
+
+func TestRangePairs(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	calls := 0
+	om.RangePairs(func(cur, next Tuple, hasNext bool) bool {
+		calls++
+		if cur.Val.(int) != calls {
+			t.Errorf("Current value was %v on call %d", cur.Val, calls)
+		}
+		if calls < 3 {
+			if !hasNext || next.Val.(int) != calls+1 {
+				t.Errorf("Next value was %v on call %d", next.Val, calls)
+			}
+		} else if hasNext || next.Key != "" {
+			t.Error("Last call had a next item")
+		}
+		return true
+	})
+	if calls != 3 {
+		t.Errorf("Callback was called %d times, expected 3", calls)
+	}
+
+	calls = 0
+	om.RangePairs(func(cur, next Tuple, hasNext bool) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Error("Range did not stop early")
+	}
+}
+
+func TestEachBatch(t *testing.T) {
+	om := New()
+	for i := 1; i <= 6; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	sizes := make([]string, 0)
+	collect := func(batch []Tuple) error {
+		sizes = append(sizes, strconv.Itoa(len(batch)))
+		return nil
+	}
+
+	if err := om.EachBatch(3, collect); err != nil {
+		t.Error("Error processing batches: " + err.Error())
+	}
+	if got := strings.Join(sizes, ","); got != "3,3" {
+		t.Errorf("Even split gave batches of %s", got)
+	}
+
+	sizes = sizes[:0]
+	om.EachBatch(4, collect)
+	if got := strings.Join(sizes, ","); got != "4,2" {
+		t.Errorf("Split with a remainder gave batches of %s", got)
+	}
+
+	calls := 0
+	err := om.EachBatch(2, func(batch []Tuple) error {
+		calls++
+		if batch[0].Key == "3" {
+			return errors.New("stop")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "stop" {
+		t.Errorf("Wrong error was returned: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Callback was called %d times, expected it to stop after 2", calls)
+	}
+
+	if err := om.EachBatch(0, collect); err == nil {
+		t.Error("No error was received for a batch size of 0.")
+	}
+}
+
+func TestScan(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+	om.Add("four", 4)
+
+	sums := om.Scan(0, func(acc interface{}, key string, value interface{}) interface{} {
+		return acc.(int) + value.(int)
+	})
+
+	expected := []int{1, 3, 6, 10}
+	if len(sums) != len(expected) {
+		t.Fatalf("Scan returned %d values, expected %d", len(sums), len(expected))
+	}
+	for i, v := range expected {
+		if sums[i].(int) != v {
+			t.Errorf("Running sum %d was %v, expected %d", i, sums[i], v)
+		}
+	}
+}
+
+func TestLongestRun(t *testing.T) {
+	om := New()
+	for i, v := range []int{1, 2, 0, 3, 4, 5, 0, 6, 7, 0} {
+		om.Add(strconv.Itoa(i), v)
+	}
+
+	start, length := om.LongestRun(func(key string, value interface{}) bool {
+		return value.(int) > 0
+	})
+	if start != 3 || length != 3 {
+		t.Errorf("Longest run was %d, %d, expected 3, 3", start, length)
+	}
+
+	start, length = om.LongestRun(func(key string, value interface{}) bool {
+		return value.(int) == 0
+	})
+	if start != 2 || length != 1 {
+		t.Errorf("Longest run was %d, %d, expected 2, 1", start, length)
+	}
+
+	start, length = om.LongestRun(func(key string, value interface{}) bool {
+		return value.(int) > 10
+	})
+	if start != -1 || length != 0 {
+		t.Errorf("Longest run with no matches was %d, %d", start, length)
+	}
+}
+
+func TestRangeKeys(t *testing.T) {
+	om := New()
+	om.Add("apple", 1)
+	om.Add("banana", 2)
+	om.Add("cherry", 3)
+	om.Add("date", 4)
+	om.Add("elderberry", 5)
+
+	res := om.RangeKeys("banana", "date", true)
+	if len(res) != 3 || res[0].Key != "banana" || res[1].Key != "cherry" || res[2].Key != "date" {
+		t.Errorf("Inclusive range returned wrong items: %v", res)
+	}
+
+	res = om.RangeKeys("banana", "date", false)
+	if len(res) != 2 || res[0].Key != "banana" || res[1].Key != "cherry" {
+		t.Errorf("Exclusive range returned wrong items: %v", res)
+	}
+
+	res = om.RangeKeys("b", "c", true)
+	if len(res) != 1 || res[0].Key != "banana" || res[0].Val.(int) != 2 {
+		t.Errorf("Prefix style range returned wrong items: %v", res)
+	}
+
+	res = om.RangeKeys("x", "z", true)
+	if len(res) != 0 {
+		t.Errorf("Range outside of keys returned items: %v", res)
+	}
+}
+
+func TestClone(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	c := om.Clone()
+	if strings.Join(c.GetOrder(), ",") != "one,two,three" {
+		t.Errorf("Clone has the wrong order: %v", c.GetOrder())
+	}
+
+	c.Set("one", TestData{ID: 10, Name: "ten"})
+	c.Delete("two")
+	c.Add("four", TestData{ID: 4, Name: "four"})
+	c.SetOrder([]string{"four", "three", "one"})
+
+	if strings.Join(om.GetOrder(), ",") != "one,two,three" {
+		t.Errorf("Changing the clone changed the original order: %v", om.GetOrder())
+	}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 1 {
+		t.Error("Changing the clone changed the original value")
+	}
+	if om.Has("four") || !om.Has("two") {
+		t.Error("Changing the clone changed the original keys")
+	}
+	if c.IndexOf("one") != 2 {
+		t.Error("Index of one in the clone was not 2")
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	empty := New()
+	om.CopyTo(empty)
+	if got := strings.Join(empty.GetOrder(), ","); got != "one,two,three" {
+		t.Errorf("Order was wrong after copying to an empty map: %s", got)
+	}
+
+	dst := New()
+	dst.Add("four", 4)
+	dst.Add("two", 20)
+	om.CopyTo(dst)
+	if got := strings.Join(dst.GetOrder(), ","); got != "four,two,one,three" {
+		t.Errorf("Order was wrong after copying to a populated map: %s", got)
+	}
+	if val, _ := dst.GetKey("two"); val.(int) != 2 {
+		t.Error("Existing value was not updated")
+	}
+
+	om.CopyTo(om)
+	if om.Count() != 3 {
+		t.Error("Copying a map into itself changed its size")
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+
+	head, tail, err := om.SplitAt(0)
+	if err != nil {
+		t.Error("Error splitting map: " + err.Error())
+	}
+	if head.Count() != 0 || tail.Count() != 4 {
+		t.Error("Split at 0 did not put everything in the tail")
+	}
+
+	head, tail, err = om.SplitAt(om.Count())
+	if err != nil {
+		t.Error("Error splitting map: " + err.Error())
+	}
+	if head.Count() != 4 || tail.Count() != 0 {
+		t.Error("Split at Count() did not put everything in the head")
+	}
+
+	head, tail, err = om.SplitAt(1)
+	if err != nil {
+		t.Error("Error splitting map: " + err.Error())
+	}
+	hord := head.GetOrder()
+	tord := tail.GetOrder()
+	if len(hord) != 1 || hord[0] != "one" {
+		t.Errorf("Head was wrong: %v", hord)
+	}
+	if len(tord) != 3 || tord[0] != "two" || tord[1] != "three" || tord[2] != "four" {
+		t.Errorf("Tail was wrong: %v", tord)
+	}
+	if val, ok := tail.GetKey("three"); !ok || val.(TestData).ID != 3 {
+		t.Error("Wrong item was returned from tail")
+	}
+
+	if om.Count() != 4 {
+		t.Error("Source map was changed by split")
+	}
+
+	_, _, err = om.SplitAt(5)
+	if err == nil {
+		t.Error("No error was received when splitting above the range.")
+	}
+	_, _, err = om.SplitAt(-1)
+	if err == nil {
+		t.Error("No error was received when splitting at a negative index.")
+	}
+}
+
+func TestFirstLastN(t *testing.T) {
+	om := New()
+	for i := 1; i <= 5; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+
+	keys := func(items []Tuple) string {
+		tmp := make([]string, len(items))
+		for i, item := range items {
+			tmp[i] = item.Key
+		}
+		return strings.Join(tmp, ",")
+	}
+
+	if got := keys(om.FirstN(2)); got != "1,2" {
+		t.Errorf("FirstN(2) returned %s", got)
+	}
+	if got := keys(om.LastN(2)); got != "4,5" {
+		t.Errorf("LastN(2) returned %s", got)
+	}
+	if got := keys(om.FirstN(10)); got != "1,2,3,4,5" {
+		t.Errorf("FirstN(10) returned %s", got)
+	}
+	if got := keys(om.LastN(10)); got != "1,2,3,4,5" {
+		t.Errorf("LastN(10) returned %s", got)
+	}
+	if len(om.FirstN(0)) != 0 || len(om.LastN(0)) != 0 {
+		t.Error("Items were returned for n of 0")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	om := New()
+	for i := 0; i < 6; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+
+	even := om.Filter(func(key string, value interface{}) bool {
+		return value.(TestData).ID%2 == 0
+	})
+	if strings.Join(even.GetOrder(), ",") != "0,2,4" {
+		t.Errorf("Filtered map had the wrong order: %v", even.GetOrder())
+	}
+	if val, _ := even.GetKey("4"); val.(TestData).ID != 4 {
+		t.Error("Filtered map had the wrong value")
+	}
+	if om.Count() != 6 {
+		t.Error("Filtering changed the original map")
+	}
+}
+
+func TestFilterAllAny(t *testing.T) {
+	om := New()
+	for i := 1; i <= 10; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+
+	even := func(key string, value interface{}) bool {
+		return value.(TestData).ID%2 == 0
+	}
+	small := func(key string, value interface{}) bool {
+		return value.(TestData).ID <= 4
+	}
+
+	ord := strings.Join(om.FilterAll(even, small).GetOrder(), ",")
+	if ord != "2,4" {
+		t.Errorf("FilterAll returned the wrong items: %s", ord)
+	}
+
+	ord = strings.Join(om.FilterAny(even, small).GetOrder(), ",")
+	if ord != "1,2,3,4,6,8,10" {
+		t.Errorf("FilterAny returned the wrong items: %s", ord)
+	}
+
+	if om.Count() != 10 {
+		t.Error("Source map was changed by filtering")
+	}
+}
+
+func TestFilterKeys(t *testing.T) {
+	om := New()
+	for i := 1; i <= 6; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+
+	keys := om.FilterKeys(func(key string, value interface{}) bool {
+		return value.(TestData).ID%3 == 0
+	})
+	if got := strings.Join(keys, ","); got != "3,6" {
+		t.Errorf("FilterKeys returned the wrong keys: %s", got)
+	}
+
+	keys = om.FilterKeys(func(key string, value interface{}) bool {
+		om.Delete(key)
+		return value.(TestData).ID%2 == 0
+	})
+	if got := strings.Join(keys, ","); got != "2,4,6" {
+		t.Errorf("FilterKeys returned the wrong keys while changing the map: %s", got)
+	}
+	if om.Count() != 0 {
+		t.Error("Map could not be changed from inside FilterKeys")
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	om := New()
+	om.Add("a1", 1)
+	om.Add("a2", 2)
+	om.Add("a3", 3)
+
+	other := New()
+	other.Add("b1", 1)
+	other.Add("b2", 2)
+	other.Add("a1", 10)
+	other.Add("b4", 4)
+	other.Add("b5", 5)
+
+	res := om.Interleave(other)
+	if got := strings.Join(res.GetOrder(), ","); got != "a1,b1,a2,b2,a3,b4,b5" {
+		t.Errorf("Interleaved order was wrong: %s", got)
+	}
+	if val, _ := res.GetKey("a1"); val.(int) != 10 {
+		t.Error("Colliding key did not take the last value")
+	}
+	if om.Count() != 3 || other.Count() != 5 {
+		t.Error("Source maps were changed by interleaving")
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	db := New()
+	db.Add("host", "localhost")
+	db.Add("port", 5432)
+
+	cache := New()
+	cache.Add("ttl", 60)
+
+	om := New()
+	om.Add("name", "app")
+	om.Add("db", db)
+	om.Add("cache", cache)
+	om.Add("debug", true)
+
+	flat := om.Flatten(".")
+	if got := strings.Join(flat.GetOrder(), ","); got != "name,db.host,db.port,cache.ttl,debug" {
+		t.Errorf("Flattened keys were wrong: %s", got)
+	}
+	if val, _ := flat.GetKey("db.port"); val.(int) != 5432 {
+		t.Error("Wrong item was returned from flattened map")
+	}
+	if om.Count() != 4 {
+		t.Error("Source map was changed by flattening")
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	om := New()
+	om.Add("name", "app")
+	om.Add("db.host", "localhost")
+	om.Add("cache.ttl", 60)
+	om.Add("db.port", 5432)
+
+	tree, err := om.Unflatten(".")
+	if err != nil {
+		t.Fatal("Error unflattening map: " + err.Error())
+	}
+	if got := strings.Join(tree.GetOrder(), ","); got != "name,db,cache" {
+		t.Errorf("Top level keys were wrong: %s", got)
+	}
+
+	val, _ := tree.GetKey("db")
+	db, ok := val.(*OrderedMap)
+	if !ok {
+		t.Fatal("Nested value was not a map")
+	}
+	if got := strings.Join(db.GetOrder(), ","); got != "host,port" {
+		t.Errorf("Nested keys were wrong: %s", got)
+	}
+	if val, _ := db.GetKey("port"); val.(int) != 5432 {
+		t.Error("Wrong item was returned from nested map")
+	}
+
+	if got := strings.Join(tree.Flatten(".").GetOrder(), ","); got != "name,db.host,db.port,cache.ttl" {
+		t.Errorf("Unflatten did not round trip with Flatten: %s", got)
+	}
+
+	bad := New()
+	bad.Add("db", "postgres")
+	bad.Add("db.host", "localhost")
+	if _, err := bad.Unflatten("."); err == nil {
+		t.Error("No error was received when a value was also a parent.")
+	}
+
+	bad = New()
+	bad.Add("db.host", "localhost")
+	bad.Add("db", "postgres")
+	if _, err := bad.Unflatten("."); err == nil {
+		t.Error("No error was received when a parent was also a value.")
+	}
+}
+
+func TestMergeFunc(t *testing.T) {
+	om := New()
+	om.Add("a", 1)
+	om.Add("b", 2)
+	om.Add("c", 3)
+
+	other := New()
+	other.Add("d", 4)
+	other.Add("b", 20)
+	other.Add("a", 10)
+
+	om.MergeFunc(other, func(key string, existing, incoming interface{}) interface{} {
+		return existing.(int) + incoming.(int)
+	})
+
+	ord := om.GetOrder()
+	if len(ord) != 4 || ord[0] != "a" || ord[1] != "b" || ord[2] != "c" || ord[3] != "d" {
+		t.Errorf("Order was wrong after merge: %v", ord)
+	}
+
+	expected := map[string]int{"a": 11, "b": 22, "c": 3, "d": 4}
+	for k, v := range expected {
+		if got, _ := om.GetKey(k); got.(int) != v {
+			t.Errorf("Value for %s was %v, expected %v", k, got, v)
+		}
+	}
+
+	om.MergeFunc(om, func(key string, existing, incoming interface{}) interface{} {
+		return existing.(int) + incoming.(int)
+	})
+	if got, _ := om.GetKey("a"); got.(int) != 22 {
+		t.Error("Merging a map into itself did not resolve values")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	om := New()
+	om.Add("one", 0)
+	om.Add("two", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				om.Update("one", func(old interface{}) interface{} {
+					return old.(int) + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if val, _ := om.GetKey("one"); val != 1000 {
+		t.Errorf("Counter was %v after concurrent updates, expected 1000", val)
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,two" {
+		t.Errorf("Order was changed by Update: %v", om.GetOrder())
+	}
+	if err := om.Update("three", func(old interface{}) interface{} { return 1 }); err == nil {
+		t.Error("No error was received when updating a missing key.")
+	}
+	if om.Has("three") {
+		t.Error("Updating a missing key added it")
+	}
+}
+
+func TestReplaceValue(t *testing.T) {
+	om := New()
+	om.Add("one", nil)
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", nil)
+
+	empty := TestData{ID: 0, Name: "empty"}
+	cnt := om.ReplaceValue(nil, empty, nil)
+	if cnt != 2 {
+		t.Errorf("Replaced %d values, expected 2", cnt)
+	}
+
+	for _, k := range []string{"one", "three"} {
+		if val, _ := om.GetKey(k); val != empty {
+			t.Errorf("Value for %s was not replaced", k)
+		}
+	}
+	if val, _ := om.GetKey("two"); val.(TestData).ID != 2 {
+		t.Error("Unrelated value was replaced")
+	}
+
+	ord := om.GetOrder()
+	if ord[0] != "one" || ord[1] != "two" || ord[2] != "three" {
+		t.Errorf("Order was changed by replacing values: %v", ord)
+	}
+
+	cnt = om.ReplaceValue(2, 3, func(a, b interface{}) bool {
+		return a.(TestData).ID == b.(int)
+	})
+	if cnt != 1 {
+		t.Errorf("Replaced %d values with custom comparison, expected 1", cnt)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", 3)
+
+	cnt := om.Migrate(func(key string, value interface{}) (interface{}, bool) {
+		if id, ok := value.(int); ok {
+			return TestData{ID: id, Name: key}, true
+		}
+		return nil, false
+	})
+	if cnt != 2 {
+		t.Errorf("Migrated %d values, expected 2", cnt)
+	}
+
+	for i, k := range []string{"one", "two", "three"} {
+		val, _ := om.GetKey(k)
+		data, ok := val.(TestData)
+		if !ok || data.ID != i+1 || data.Name != k {
+			t.Errorf("Value for %s was not migrated correctly: %v", k, val)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("tmp-id", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	if err := om.Rename("tmp-id", "two"); err != nil {
+		t.Error("Error renaming key: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,two,three" {
+		t.Errorf("Order was wrong after renaming: %v", om.GetOrder())
+	}
+	if val, ok := om.GetKey("two"); !ok || val.(TestData).ID != 2 {
+		t.Error("Renamed key did not keep its value")
+	}
+	if om.Has("tmp-id") {
+		t.Error("Old key still exists after renaming")
+	}
+	if om.IndexOf("two") != 1 {
+		t.Error("Index of renamed key was not 1")
+	}
+
+	if err := om.Rename("one", "three"); err == nil {
+		t.Error("No error was received when renaming onto an existing key.")
+	}
+	if err := om.Rename("four", "five"); err == nil {
+		t.Error("No error was received when renaming a missing key.")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("four", TestData{ID: 4, Name: "four"})
+
+	if err := om.Swap("one", "four"); err != nil {
+		t.Error("Error swapping keys: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "four,two,three,one" {
+		t.Errorf("Order was wrong after swapping: %v", om.GetOrder())
+	}
+	if om.IndexOf("one") != 3 || om.IndexOf("four") != 0 {
+		t.Error("Indexes were not updated after swapping")
+	}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 1 {
+		t.Error("Swapping keys changed a value")
+	}
+	if err := om.Swap("one", "five"); err == nil {
+		t.Error("No error was received when swapping a missing key.")
+	}
+}
+
+func TestSwapValues(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	err := om.SwapValues("one", "three")
+	if err != nil {
+		t.Error("Error swapping values: " + err.Error())
+	}
+
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 3 {
+		t.Error("Value of one was not swapped")
+	}
+	if val, _ := om.GetKey("three"); val.(TestData).ID != 1 {
+		t.Error("Value of three was not swapped")
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "one,two,three" {
+		t.Errorf("Order was changed by swapping values: %s", got)
+	}
+
+	if err := om.SwapValues("one", "four"); err == nil {
+		t.Error("No error was received when swapping with a missing key.")
+	}
+	if val, _ := om.GetKey("one"); val.(TestData).ID != 3 {
+		t.Error("Value was changed by a failed swap")
+	}
+}
+
+func TestDuplicateValues(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("uno", TestData{ID: 1, Name: "one"})
+
+	dups := om.DuplicateValues(nil)
+	if len(dups) != 1 {
+		t.Fatalf("Found %d groups of duplicates, expected 1", len(dups))
+	}
+	if got := strings.Join(dups[0], ","); got != "one,uno" {
+		t.Errorf("Wrong keys were grouped: %s", got)
+	}
+
+	dups = om.DuplicateValues(func(a, b interface{}) bool {
+		return true
+	})
+	if len(dups) != 1 || len(dups[0]) != 3 {
+		t.Errorf("Custom comparison grouped the wrong keys: %v", dups)
+	}
+}
+
+func TestDedupeByValue(t *testing.T) {
+	om := New()
+	om.Add("a", 1)
+	om.Add("b", 2)
+	om.Add("c", 1)
+	om.Add("d", 3)
+	om.Add("e", 2)
+	om.Add("f", 1)
+
+	cnt := om.DedupeByValue(nil)
+	if cnt != 3 {
+		t.Errorf("Removed %d items, expected 3", cnt)
+	}
+	if got := strings.Join(om.GetOrder(), ","); got != "a,b,d" {
+		t.Errorf("Wrong items were kept: %s", got)
+	}
+	if om.Count() != 3 {
+		t.Error("Size of ordered map was wrong")
+	}
+	if _, ok := om.GetKey("c"); ok {
+		t.Error("Duplicate key still exists")
+	}
+}
+
+func TestSetKeys(t *testing.T) {
+	om := New()
+	om.Add("a", 1)
+	om.Add("b", 2)
+	om.Add("c", 3)
+
+	overlap := New()
+	overlap.Add("d", 4)
+	overlap.Add("c", 3)
+	overlap.Add("a", 1)
+
+	disjoint := New()
+	disjoint.Add("x", 1)
+	disjoint.Add("y", 2)
+
+	tests := []struct {
+		name  string
+		other *OrderedMap
+		inter string
+		union string
+		diff  string
+		sym   string
+	}{
+		{"overlapping", overlap, "a,c", "a,b,c,d", "b", "b,d"},
+		{"disjoint", disjoint, "", "a,b,c,x,y", "a,b,c", "a,b,c,x,y"},
+		{"identical", om, "a,b,c", "a,b,c", "", ""},
+	}
+
+	for _, test := range tests {
+		if got := strings.Join(om.IntersectKeys(test.other), ","); got != test.inter {
+			t.Errorf("%s: IntersectKeys returned %s", test.name, got)
+		}
+		if got := strings.Join(om.UnionKeys(test.other), ","); got != test.union {
+			t.Errorf("%s: UnionKeys returned %s", test.name, got)
+		}
+		if got := strings.Join(om.DifferenceKeys(test.other), ","); got != test.diff {
+			t.Errorf("%s: DifferenceKeys returned %s", test.name, got)
+		}
+		if got := strings.Join(om.SymmetricDifferenceKeys(test.other), ","); got != test.sym {
+			t.Errorf("%s: SymmetricDifferenceKeys returned %s", test.name, got)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	same := New()
+	same.Add("one", TestData{ID: 1, Name: "one"})
+	same.Add("two", TestData{ID: 2, Name: "two"})
+	if !om.Equal(same) || !om.Equal(om) {
+		t.Error("Maps with the same items in the same order were not equal")
+	}
+
+	reordered := New()
+	reordered.Add("two", TestData{ID: 2, Name: "two"})
+	reordered.Add("one", TestData{ID: 1, Name: "one"})
+	if om.Equal(reordered) {
+		t.Error("Maps with the same items in a different order were equal")
+	}
+
+	changed := New()
+	changed.Add("one", TestData{ID: 1, Name: "one"})
+	changed.Add("two", TestData{ID: 20, Name: "twenty"})
+	if om.Equal(changed) {
+		t.Error("Maps with different values were equal")
+	}
+}
+
+func TestEqualUnordered(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	reordered := New()
+	reordered.Add("two", TestData{ID: 2, Name: "two"})
+	reordered.Add("one", TestData{ID: 1, Name: "one"})
+	if !om.EqualUnordered(reordered) || !om.EqualUnordered(om) {
+		t.Error("Maps with the same items in a different order were not equal")
+	}
+
+	changed := New()
+	changed.Add("two", TestData{ID: 20, Name: "twenty"})
+	changed.Add("one", TestData{ID: 1, Name: "one"})
+	if om.EqualUnordered(changed) {
+		t.Error("Maps with different values were equal")
+	}
+
+	extra := New()
+	extra.Add("one", TestData{ID: 1, Name: "one"})
+	extra.Add("three", TestData{ID: 3, Name: "three"})
+	if om.EqualUnordered(extra) {
+		t.Error("Maps with different keys were equal")
+	}
+}
+
+func TestSameOrder(t *testing.T) {
+	om := New()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	same := New()
+	same.Add("one", 10)
+	same.Add("two", 20)
+	same.Add("three", 30)
+
+	shuffled := New()
+	shuffled.Add("two", 2)
+	shuffled.Add("one", 1)
+	shuffled.Add("three", 3)
+
+	different := New()
+	different.Add("one", 1)
+	different.Add("two", 2)
+	different.Add("four", 4)
+
+	if !om.SameOrder(same) {
+		t.Error("Maps with the same order were not the same")
+	}
+	if !om.SameOrder(om) {
+		t.Error("Map was not the same as itself")
+	}
+	if om.SameOrder(shuffled) {
+		t.Error("Maps with the same keys in a different order were the same")
+	}
+	if om.SameOrder(different) {
+		t.Error("Maps with different keys were the same")
+	}
+}
+
+func TestAsTyped(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	items, err := AsTyped[TestData](om)
+	if err != nil {
+		t.Error("Error converting map values: " + err.Error())
+	}
+	if len(items) != 3 {
+		t.Fatal("Converted slice does not contain three items")
+	}
+	if items[1].Key != "two" || items[1].Val.ID != 2 {
+		t.Error("Wrong item was returned from conversion")
+	}
+
+	om.Insert(1, "bad", "not test data")
+	items, err = AsTyped[TestData](om)
+	if err == nil {
+		t.Error("No error was received when converting a mismatched value")
+	} else if !strings.Contains(err.Error(), `"bad"`) {
+		t.Errorf("Error did not identify the mismatched key: %v", err)
+	}
+	if items != nil {
+		t.Error("Items were returned along with an error")
+	}
+}
+
+func TestToURLValues(t *testing.T) {
+	om := New()
+	om.Add("zeta", 1)
+	om.Add("alpha", TestData{ID: 2, Name: "two"})
+	om.Add("mid", "three")
+
+	vals, keys := om.ToURLValues(func(v interface{}) string {
+		if data, ok := v.(TestData); ok {
+			return data.Name
+		}
+		return fmt.Sprint(v)
+	})
+
+	if got := strings.Join(keys, ","); got != "zeta,alpha,mid" {
+		t.Errorf("Key order was wrong: %s", got)
+	}
+	if vals.Get("zeta") != "1" || vals.Get("alpha") != "two" || vals.Get("mid") != "three" {
+		t.Errorf("Values were wrong: %v", vals)
+	}
+
+	vals, _ = om.ToURLValues(nil)
+	if vals.Get("zeta") != "1" {
+		t.Error("Default conversion was wrong")
+	}
+}
+
+func TestCollectInto(t *testing.T) {
+	om := New()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	convert := func(key string, value interface{}) (string, error) {
+		data, ok := value.(TestData)
+		if !ok {
+			return "", fmt.Errorf("Value for %s is not TestData", key)
+		}
+		return fmt.Sprintf("%d:%s", data.ID, data.Name), nil
+	}
+
+	res, err := CollectInto(om, convert)
+	if err != nil {
+		t.Error("Error collecting values: " + err.Error())
+	}
+	if got := strings.Join(res, ","); got != "1:one,2:two,3:three" {
+		t.Errorf("Collected the wrong values: %s", got)
+	}
+
+	om.Insert(1, "bad", 5)
+	calls := 0
+	res, err = CollectInto(om, func(key string, value interface{}) (string, error) {
+		calls++
+		return convert(key, value)
+	})
+	if err == nil {
+		t.Error("No error was received when a conversion failed.")
+	}
+	if res != nil {
+		t.Error("Results were returned along with an error")
+	}
+	if calls != 2 {
+		t.Errorf("Conversion was called %d times, expected it to stop after 2", calls)
+	}
+}
+
+func TestClear(t *testing.T) {
 	om := New()
-
 	om.Add("one", TestData{ID: 1, Name: "one"})
 	om.Add("two", TestData{ID: 2, Name: "two"})
-	om.Add("three", TestData{ID: 3, Name: "three"})
+	order := om.GetOrder()
 
-	test, ok := om.GetKey("two")
-	gotten := test.(TestData)
-
-	if !ok {
-		t.Error("Unable to get item from map by key")
+	om.Clear()
+	if om.Count() != 0 {
+		t.Error("Map was not empty after clear")
+	}
+	if _, ok := om.GetKey("one"); ok {
+		t.Error("Cleared key still exists")
+	}
+	itr := om.Iterator()
+	for _ = range itr.Loop() {
+		t.Error("Iterating a cleared map returned an item")
 	}
 
-	if gotten.ID != 2 || gotten.Name != "two" {
-		t.Error("Wrong item was returned from map")
+	om.Add("three", TestData{ID: 3, Name: "three"})
+	if got := strings.Join(om.GetOrder(), ","); got != "three" {
+		t.Errorf("Order was wrong after reuse: %s", got)
+	}
+	if order[0] != "one" {
+		t.Error("Reusing a cleared map changed a previously returned order")
 	}
 }
 
-func TestGetIndex(t *testing.T) {
+func TestDrain(t *testing.T) {
 	om := New()
 	om.Add("one", TestData{ID: 1, Name: "one"})
 	om.Add("two", TestData{ID: 2, Name: "two"})
+
+	items := om.Drain()
+	if len(items) != 2 || items[0].Key != "one" || items[1].Key != "two" {
+		t.Errorf("Drain returned the wrong items: %v", items)
+	}
+	if om.Count() != 0 || len(om.GetOrder()) != 0 {
+		t.Error("Map was not empty after drain")
+	}
+
 	om.Add("three", TestData{ID: 3, Name: "three"})
+	if om.Count() != 1 {
+		t.Error("Map did not accept items after drain")
+	}
+	om.Drain()
 
-	key, val, ok := om.GetIndex(1)
-	gotten := val.(TestData)
+	const total = 1000
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < total; i++ {
+			om.Add(strconv.Itoa(i), i)
+		}
+		done <- true
+	}()
 
-	if !ok {
-		t.Error("Unable to get item from map by index")
+	seen := make(map[string]bool)
+	finished := false
+	for !finished {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		for _, item := range om.Drain() {
+			if seen[item.Key] {
+				t.Errorf("Key %s was drained twice", item.Key)
+			}
+			seen[item.Key] = true
+		}
 	}
 
-	if key != "two" || gotten.ID != 2 || gotten.Name != "two" {
-		t.Error("Wrong item was returned from map")
+	if len(seen) != total {
+		t.Errorf("Drained %d items, expected %d", len(seen), total)
 	}
 }
 
-func TestGetOrder(t *testing.T) {
+func TestIterator(t *testing.T) {
 	om := New()
-	om.Add("one", TestData{ID: 1, Name: "one"})
-	om.Add("two", TestData{ID: 2, Name: "two"})
-	om.Add("three", TestData{ID: 3, Name: "three"})
+	for i := 0; i < 100; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
 
-	ord := om.GetOrder()
-	if ord[0] != "one" {
-		t.Error("First item was wrong")
-	} else if ord[1] != "two" {
-		t.Error("Second item was wrong")
-	} else if ord[2] != "three" {
-		t.Error("Third item was wrong")
+	itr := om.Iterator()
+	j := 0
+	for item := range itr.Loop() {
+		if item.Key != strconv.Itoa(j) {
+			t.Errorf("Index %v did not match", j)
+		}
+		j++
 	}
 }
 
-func TestSetOrder(t *testing.T) {
+func TestIteratorBreak(t *testing.T) {
 	om := New()
-	om.Add("one", TestData{ID: 1, Name: "one"})
-	om.Add("two", TestData{ID: 2, Name: "two"})
-	om.Add("three", TestData{ID: 3, Name: "three"})
+	for i := 0; i < 1000; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
 
-	err := om.SetOrder([]string{"three", "one", "two"})
-	if err != nil {
-		t.Error("An error occured setting order: " + err.Error())
+	itr := om.Iterator()
+	j := 0
+	for _ = range itr.Loop() {
+		if j == 60 {
+			itr.Break()
+			break
+		}
+		j++
 	}
+}
 
-	ord := om.GetOrder()
-	if ord[0] != "three" {
-		t.Error("First item was wrong")
-	} else if ord[1] != "one" {
-		t.Error("Second item was wrong")
-	} else if ord[2] != "two" {
-		t.Error("Third item was wrong")
+func TestIteratorLoopReverse(t *testing.T) {
+	om := New()
+	for i := 0; i < 100; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
 	}
 
-	err = om.SetOrder([]string{"three", "one", "two", "five", "eleventy"})
-	if err == nil {
-		t.Error("No error occured when trying to use an order that was too large")
+	itr := om.Iterator()
+	j := 99
+	for item := range itr.LoopReverse() {
+		if item.Key != strconv.Itoa(j) {
+			t.Errorf("Index %v did not match", j)
+		}
+		j--
+	}
+	if j != -1 {
+		t.Error("Reverse loop did not return every item")
 	}
 
-	err = om.SetOrder([]string{"three", "one", "five"})
-	if err == nil {
-		t.Error("No error occured when trying to use an order the right size, but with the wrong items")
+	brk := om.Iterator()
+	for item := range brk.LoopReverse() {
+		if item.Key == "60" {
+			brk.Break()
+			break
+		}
 	}
 }
 
-func TestIndexOf(t *testing.T) {
+func TestIteratorLoopReverseDuplicate(t *testing.T) {
 	om := New()
-	om.Add("one", TestData{ID: 1, Name: "one"})
-	om.Add("two", TestData{ID: 2, Name: "two"})
-	om.Add("three", TestData{ID: 3, Name: "three"})
+	om.Add("a", 1)
+	om.Add("a", 2)
+	om.Add("b", 3)
 
-	idx := om.IndexOf("one")
-	if idx != 0 {
-		t.Error("Index of one was not 0")
+	keys := make([]string, 0)
+	itr := om.Iterator()
+	for item := range itr.LoopReverse() {
+		keys = append(keys, item.Key)
 	}
-	idx = om.IndexOf("three")
-	if idx != 2 {
-		t.Error("Index of three was not 2")
+	if strings.Join(keys, ",") != "b,a,a" {
+		t.Errorf("Reverse loop returned %v, expected every key in the order", keys)
 	}
 }
 
-func TestDelete(t *testing.T) {
+func TestIteratorContextLeak(t *testing.T) {
 	om := New()
-	om.Add("one", TestData{ID: 1, Name: "one"})
-	om.Add("two", TestData{ID: 2, Name: "two"})
-	om.Add("three", TestData{ID: 3, Name: "three"})
+	for i := 0; i < 100; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
 
-	om.Delete("two")
-	_, ok := om.GetKey("two")
-	if ok {
-		t.Error("Deleted key still exists")
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 10; i++ {
+		itr := om.IteratorContext(ctx)
+		for range itr.Loop() {
+			break
+		}
+		rev := om.IteratorContext(ctx)
+		for range rev.LoopReverse() {
+			break
+		}
 	}
-	if om.Count() != 2 {
-		t.Error("Size of ordered map was wrong")
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Abandoned iterators leaked goroutines: %d before, %d after", before, after)
 	}
 }
 
-func TestCount(t *testing.T) {
+func TestIteratorContextCancelBreak(t *testing.T) {
 	om := New()
-	om.Add("one", TestData{ID: 1, Name: "one"})
-	if om.Count() != 1 {
-		t.Error("First count was wrong")
+	for i := 0; i < 100; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
 	}
-	om.Add("two", TestData{ID: 2, Name: "two"})
-	if om.Count() != 2 {
-		t.Error("Second count was wrong")
+
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		itr := om.IteratorContext(ctx)
+		ch := itr.Loop()
+		<-ch
+		cancel()
+		itr.Break()
 	}
-	om.Add("three", TestData{ID: 3, Name: "three"})
-	if om.Count() != 3 {
-		t.Error("Third count was wrong")
+
+	itr := om.Iterator()
+	for range itr.Loop() {
 	}
+	itr.Break()
 }
 
-func TestIterator(t *testing.T) {
+func TestAll(t *testing.T) {
 	om := New()
 	for i := 0; i < 100; i++ {
 		str := strconv.Itoa(i)
 		om.Add(str, TestData{ID: i, Name: str})
 	}
 
-	itr := om.Iterator()
 	j := 0
-	for item := range itr.Loop() {
+	for key, val := range om.All() {
+		if key != strconv.Itoa(j) || val.(TestData).ID != j {
+			t.Errorf("Index %v did not match", j)
+		}
+		om.Delete(key)
+		j++
+	}
+	if j != 100 {
+		t.Error("All did not return every item")
+	}
+
+	om.Add("one", 1)
+	om.Add("two", 2)
+	j = 0
+	for range om.All() {
+		j++
+		break
+	}
+	if j != 1 {
+		t.Error("Breaking out of All did not stop the loop")
+	}
+}
+
+func TestStream(t *testing.T) {
+	om := New()
+	for i := 0; i < 100; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+
+	j := 0
+	for item := range om.Stream(context.Background(), 0) {
 		if item.Key != strconv.Itoa(j) {
 			t.Errorf("Index %v did not match", j)
 		}
 		j++
 	}
+	if j != 100 {
+		t.Errorf("Streamed %d items, expected 100", j)
+	}
 }
 
-func TestIteratorBreak(t *testing.T) {
+func TestStreamCancel(t *testing.T) {
 	om := New()
 	for i := 0; i < 1000; i++ {
 		str := strconv.Itoa(i)
 		om.Add(str, TestData{ID: i, Name: str})
 	}
 
-	itr := om.Iterator()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := om.Stream(ctx, 0)
 	j := 0
-	for _ = range itr.Loop() {
+	for _ = range stream {
 		if j == 60 {
-			itr.Break()
+			cancel()
 			break
 		}
 		j++
 	}
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-stream:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Stream was not closed after cancelling")
+		}
+	}
+}
+
+func TestStreamBuffer(t *testing.T) {
+	om := New()
+	for i := 0; i < 10; i++ {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := om.Stream(ctx, 5)
+
+	deadline := time.Now().Add(time.Second)
+	for len(stream) < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if len(stream) != 5 {
+		t.Errorf("Stream buffered %d items, expected 5", len(stream))
+	}
+
+	j := 0
+	for item := range stream {
+		if item.Key != strconv.Itoa(j) {
+			t.Errorf("Index %v did not match", j)
+		}
+		j++
+	}
+	if j != 10 {
+		t.Errorf("Streamed %d items, expected 10", j)
+	}
 }
 
 func ExampleIterator_full() {
@@ -274,3 +3132,169 @@ func ExampleIterator_break() {
 		fmt.Printf("%s > %v\n", data.Key, data.Val)
 	}
 }
+
+func benchmarkSortMap(n int) *OrderedMap {
+	om := New()
+	for i := n; i > 0; i-- {
+		str := strconv.Itoa(i)
+		om.Add(str, TestData{ID: i, Name: str})
+	}
+	return om
+}
+
+func BenchmarkOrderBy(b *testing.B) {
+	om := benchmarkSortMap(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		om.OrderBy(func(aKey string, aVal interface{}, bKey string, bVal interface{}) bool {
+			if i%2 == 0 {
+				return aVal.(TestData).ID < bVal.(TestData).ID
+			}
+			return aVal.(TestData).ID > bVal.(TestData).ID
+		})
+	}
+}
+
+func BenchmarkSetOrderSort(b *testing.B) {
+	om := benchmarkSortMap(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ord := om.GetOrder()
+		sort.SliceStable(ord, func(x, y int) bool {
+			a, _ := om.GetKey(ord[x])
+			c, _ := om.GetKey(ord[y])
+			if i%2 == 0 {
+				return a.(TestData).ID < c.(TestData).ID
+			}
+			return a.(TestData).ID > c.(TestData).ID
+		})
+		om.SetOrder(ord)
+	}
+}
+
+func benchmarkAddGet(b *testing.B, om *OrderedMap) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := keys[i%len(keys)]
+		om.Add(k, i)
+		om.GetKey(k)
+		if i%len(keys) == len(keys)-1 {
+			om.Drain()
+		}
+	}
+}
+
+func BenchmarkAddGetLocked(b *testing.B) {
+	om := New()
+	benchmarkAddGet(b, om)
+}
+
+func BenchmarkAddGetUnsafe(b *testing.B) {
+	benchmarkAddGet(b, NewUnsafe())
+}
+
+func benchmarkIndexMap(n int) (*OrderedMap, []string) {
+	om := New()
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		om.Set(keys[i], i)
+	}
+	return om, keys
+}
+
+func BenchmarkIndexOf(b *testing.B) {
+	om, keys := benchmarkIndexMap(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		om.IndexOf(keys[i%len(keys)])
+	}
+}
+
+func benchmarkDeleteSome(b *testing.B, pick func(keys []string, j int) string) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		om, keys := benchmarkIndexMap(50000)
+		b.StartTimer()
+		for j := 0; j < 1000; j++ {
+			om.Delete(pick(keys, j))
+		}
+	}
+}
+
+func BenchmarkDeleteFromFront(b *testing.B) {
+	benchmarkDeleteSome(b, func(keys []string, j int) string {
+		return keys[j]
+	})
+}
+
+func BenchmarkDeleteFromMiddle(b *testing.B) {
+	benchmarkDeleteSome(b, func(keys []string, j int) string {
+		return keys[len(keys)/2+j]
+	})
+}
+
+func BenchmarkPopFront(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		om, _ := benchmarkIndexMap(50000)
+		b.StartTimer()
+		for om.Count() > 0 {
+			om.PopFront()
+		}
+	}
+}
+
+func BenchmarkAddBounded(b *testing.B) {
+	om := New()
+	for i := 0; i < 50000; i++ {
+		om.Add(strconv.Itoa(i), i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		om.AddBounded(strconv.Itoa(50000+i), i, 50000)
+	}
+}
+
+func BenchmarkDeleteFromBack(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		om, keys := benchmarkIndexMap(50000)
+		b.StartTimer()
+		for j := len(keys) - 1; j >= 0; j-- {
+			om.Delete(keys[j])
+		}
+	}
+}
+
+func benchmarkBulkAdd(b *testing.B, hint bool) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var om *OrderedMap
+		if hint {
+			om = NewWithCapacity(len(keys))
+		} else {
+			om = New()
+		}
+		for j, k := range keys {
+			om.Add(k, j)
+		}
+	}
+}
+
+func BenchmarkBulkAdd(b *testing.B) {
+	benchmarkBulkAdd(b, false)
+}
+
+func BenchmarkBulkAddWithCapacity(b *testing.B) {
+	benchmarkBulkAdd(b, true)
+}
@@ -0,0 +1,361 @@
+/*
+Provides a version of orderedmap that uses type parameters, so that values come
+back out of the map already typed rather than as an interface{} that has to be
+asserted.  Map keys must still be strings.
+
+	om := typed.NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+
+	val, ok := om.GetKey("one") // val is an int
+
+Ordering and locking work the same way as the untyped map, and every method is
+safe for concurrent use.  One difference is that a key is never in a typed map
+more than once: Add updates the value of a key that already exists, keeping its
+position, where the untyped Add appends a second copy of the key.
+*/
+package typed
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sort"
+	"sync"
+)
+
+// A map structure that stores values of type V within an ordered fashion.
+type OrderedMap[V any] struct {
+	data  map[string]V
+	order []string
+	index map[string]int
+	lock  sync.RWMutex
+}
+
+// Create a new typed ordered map object
+func NewTyped[V any]() *OrderedMap[V] {
+	return &OrderedMap[V]{
+		data:  make(map[string]V),
+		order: make([]string, 0),
+		index: make(map[string]int),
+	}
+}
+
+// Set the value of a key in the map.  If the key already exists, its value is
+// updated and it keeps its current position; otherwise it is added onto the end
+// of the map.
+func (m *OrderedMap[V]) Set(key string, value V) {
+	m.lock.Lock()
+	if _, ok := m.data[key]; !ok {
+		m.index[key] = len(m.order)
+		m.order = append(m.order, key)
+	}
+	m.data[key] = value
+	m.lock.Unlock()
+}
+
+// Add an object onto the end of the map.  If the key already exists, its value
+// is updated in place instead, so a key is never in the map twice.
+func (m *OrderedMap[V]) Add(key string, value V) {
+	m.Set(key, value)
+}
+
+// Add an object to a specific position in the map.  Position is zero indexed,
+// so to add to the very beginning, you would use 0, to add to the end you would
+// use Count().  If the key already exists, its value is updated and it is moved
+// so that it ends up at position; in that case the end of the map is
+// Count() - 1.
+func (m *OrderedMap[V]) Insert(position int, key string, value V) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, exists := m.data[key]
+	size := len(m.order)
+	if exists {
+		size--
+	}
+
+	if position > size {
+		return errors.New("Position is larger than the current map size.")
+	}
+
+	if position < 0 {
+		return errors.New("Position is less than 0.")
+	}
+
+	if exists {
+		m.removeIndex(m.index[key])
+	}
+	m.data[key] = value
+	m.order = append(m.order, "")
+	copy(m.order[position+1:], m.order[position:])
+	m.order[position] = key
+	m.reindex(position)
+
+	return nil
+}
+
+// Get a specific object out of the map based on its map key
+func (m *OrderedMap[V]) GetKey(key string) (V, bool) {
+	m.lock.RLock()
+	data, ok := m.data[key]
+	m.lock.RUnlock()
+	return data, ok
+}
+
+// Get a specific object out of the map based on its order index.  Returns false
+// if the index is out of range.
+func (m *OrderedMap[V]) GetIndex(index int) (string, V, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if index < 0 || index >= len(m.order) {
+		var zero V
+		return "", zero, false
+	}
+	key := m.order[index]
+	return key, m.data[key], true
+}
+
+// Test if a key exists in the map.
+func (m *OrderedMap[V]) Has(key string) bool {
+	m.lock.RLock()
+	_, ok := m.data[key]
+	m.lock.RUnlock()
+	return ok
+}
+
+// Get the order index of a specific key, or -1 if it does not exist.
+func (m *OrderedMap[V]) IndexOf(key string) int {
+	m.lock.RLock()
+	index, ok := m.index[key]
+	m.lock.RUnlock()
+	if !ok {
+		return -1
+	}
+	return index
+}
+
+// Get the current order of the map's keys.  The returned slice is a copy.
+func (m *OrderedMap[V]) GetOrder() []string {
+	m.lock.RLock()
+	tmp := make([]string, len(m.order))
+	copy(tmp, m.order)
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Get every value in the map, in order.
+func (m *OrderedMap[V]) Values() []V {
+	m.lock.RLock()
+	tmp := make([]V, len(m.order))
+	for i, k := range m.order {
+		tmp[i] = m.data[k]
+	}
+	m.lock.RUnlock()
+	return tmp
+}
+
+// Delete a specific key and all associated data from the map.  Deleting a key
+// that does not exist does nothing.
+func (m *OrderedMap[V]) Delete(key string) {
+	m.lock.Lock()
+	if idx, ok := m.index[key]; ok {
+		m.removeIndex(idx)
+	}
+	m.lock.Unlock()
+}
+
+// Delete the object at a specific position in the map, along with all
+// associated data.  Position is zero indexed, and the items after it move
+// forward by one.
+func (m *OrderedMap[V]) DeleteIndex(position int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if position >= len(m.order) {
+		return errors.New("Position is larger than the current map size.")
+	}
+
+	if position < 0 {
+		return errors.New("Position is less than 0.")
+	}
+
+	m.removeIndex(position)
+	return nil
+}
+
+// Remove every item from the map, leaving it empty and ready to be reused.
+func (m *OrderedMap[V]) Clear() {
+	m.lock.Lock()
+	m.data = make(map[string]V)
+	m.order = m.order[:0]
+	m.index = make(map[string]int)
+	m.lock.Unlock()
+}
+
+// Set a new order for this map.  SetOrder will return an error if either the
+// number of items in the provided slice is different than those in the map, or
+// if the keys are different that those currently in use.
+func (m *OrderedMap[V]) SetOrder(order []string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !compareOrder(m.order, order) {
+		return errors.New("Provided order does not contain the same data as existing.")
+	}
+	copy(m.order, order)
+	m.reindex(0)
+	return nil
+}
+
+// Get the number of items in the map
+func (m *OrderedMap[V]) Count() int {
+	m.lock.RLock()
+	cnt := len(m.order)
+	m.lock.RUnlock()
+	return cnt
+}
+
+// Remove the item at a specific order index.  The caller must hold the write
+// lock and make sure the index is in range.
+func (m *OrderedMap[V]) removeIndex(index int) {
+	key := m.order[index]
+	m.order = append(m.order[:index], m.order[index+1:]...)
+	delete(m.data, key)
+	delete(m.index, key)
+	m.reindex(index)
+}
+
+// Update the index of every key from a specific order index onwards, after the
+// order has changed from that point.  The caller must hold the write lock.
+func (m *OrderedMap[V]) reindex(from int) {
+	for i := from; i < len(m.order); i++ {
+		m.index[m.order[i]] = i
+	}
+}
+
+// Compare two orders and determine if they have the same keys even if not in
+// the same order.
+func compareOrder(f []string, s []string) bool {
+	if len(f) != len(s) {
+		return false
+	}
+
+	tmpf := make([]string, len(f))
+	tmps := make([]string, len(s))
+	copy(tmpf, f)
+	copy(tmps, s)
+	sort.Strings(tmpf)
+	sort.Strings(tmps)
+
+	for i, v := range tmps {
+		if tmpf[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// An iterator that loops through a typed ordered map, in order.
+type OrderedMapIterator[V any] struct {
+	returnchan chan Tuple[V]
+	breakchan  chan bool
+	done       chan struct{}
+	data       *OrderedMap[V]
+	ctx        context.Context
+}
+
+// A data structure to hold returned information on each iteration
+type Tuple[V any] struct {
+	Key string
+	Val V
+}
+
+// Returns an OrderedMapIterator type that can be used to loop through the
+// entire map, in order.
+//
+// IMPORTANT NOTE: You must use the Break() function before you use the break
+// go command, otherwise you might have deadlock, race, or garbage issues.  If
+// you cannot guarantee that, use IteratorContext instead.
+func (m *OrderedMap[V]) Iterator() OrderedMapIterator[V] {
+	return m.IteratorContext(context.Background())
+}
+
+// Returns an OrderedMapIterator type that works the same way as Iterator, but
+// that also stops looping once ctx is cancelled, so a loop that is abandoned
+// without calling Break() does not leave anything running.
+func (m *OrderedMap[V]) IteratorContext(ctx context.Context) OrderedMapIterator[V] {
+	return OrderedMapIterator[V]{
+		returnchan: make(chan Tuple[V]),
+		breakchan:  make(chan bool),
+		done:       make(chan struct{}),
+		data:       m,
+		ctx:        ctx,
+	}
+}
+
+// Provides access to a channel that will allow looping through the entire
+// map in order.  Returns a channel that can be passed to range and returns a
+// Tuple struct with the key and typed value of each item.  The items are
+// copied when looping starts.
+//
+// 	iter = mymap.Iterator()
+// 	for data := range iter.Loop() {
+// 		fmt.Printf("%s > %v\n", data.Key, data.Val)
+// 	}
+func (it *OrderedMapIterator[V]) Loop() <-chan Tuple[V] {
+	go func() {
+		defer close(it.done)
+		defer close(it.returnchan)
+
+		for _, t := range it.data.tuples() {
+			select {
+			case it.returnchan <- t:
+			case <-it.breakchan:
+				return
+			case <-it.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return it.returnchan
+}
+
+// Signals the iterator that you no longer want to loop, allowing us to clean
+// up and stop looping.
+func (it *OrderedMapIterator[V]) Break() {
+	select {
+	case it.breakchan <- true:
+	case <-it.done:
+	case <-it.ctx.Done():
+	}
+}
+
+// Get an iterator over every item in the map, in order, for use with range.
+// Unlike Iterator, breaking out of the loop early needs no cleanup.  The items
+// are copied from the map when the loop starts.
+//
+// 	for key, val := range mymap.All() {
+// 		fmt.Printf("%s > %v\n", key, val)
+// 	}
+func (m *OrderedMap[V]) All() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for _, t := range m.tuples() {
+			if !yield(t.Key, t.Val) {
+				return
+			}
+		}
+	}
+}
+
+// Copy every item in the map, in order.
+func (m *OrderedMap[V]) tuples() []Tuple[V] {
+	m.lock.RLock()
+	tmp := make([]Tuple[V], len(m.order))
+	for i, k := range m.order {
+		tmp[i] = Tuple[V]{k, m.data[k]}
+	}
+	m.lock.RUnlock()
+	return tmp
+}
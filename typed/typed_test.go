@@ -0,0 +1,245 @@
+package typed
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type TestData struct {
+	ID   int
+	Name string
+}
+
+func TestAddGet(t *testing.T) {
+	om := NewTyped[TestData]()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("one", TestData{ID: 10, Name: "ten"})
+
+	if om.Count() != 2 {
+		t.Error("Map does not contain correct number of items")
+	}
+
+	val, ok := om.GetKey("one")
+	if !ok || val.ID != 10 {
+		t.Error("Typed value from GetKey was wrong")
+	}
+	if _, ok := om.GetKey("three"); ok {
+		t.Error("Found a key that does not exist")
+	}
+
+	key, val, ok := om.GetIndex(1)
+	if !ok || key != "two" || val.Name != "two" {
+		t.Error("Typed value from GetIndex was wrong")
+	}
+	if _, _, ok := om.GetIndex(2); ok {
+		t.Error("Found an index that is out of range")
+	}
+}
+
+func TestInsert(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("three", 3)
+
+	if err := om.Insert(1, "two", 2); err != nil {
+		t.Error("Error trying to insert into ordered map: " + err.Error())
+	}
+	if err := om.Insert(om.Count(), "four", 4); err != nil {
+		t.Error("Error trying to insert at the end of ordered map: " + err.Error())
+	}
+	if err := om.Insert(0, "four", 4); err != nil {
+		t.Error("Error trying to insert an existing key: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "four,one,two,three" {
+		t.Errorf("Order was wrong after inserting: %v", om.GetOrder())
+	}
+	if om.IndexOf("three") != 3 {
+		t.Error("Index of three was not 3")
+	}
+
+	if err := om.Insert(5, "six", 6); err == nil {
+		t.Error("No error was received when trying to insert above the range.")
+	}
+	if err := om.Insert(-1, "six", 6); err == nil {
+		t.Error("No error was received when trying to insert negative value.")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	om.Delete("two")
+	om.Delete("missing")
+	if om.Has("two") {
+		t.Error("Deleted key still exists")
+	}
+	if om.IndexOf("three") != 1 {
+		t.Error("Index of three was not updated after delete")
+	}
+	vals := om.Values()
+	if len(vals) != 2 || vals[0] != 1 || vals[1] != 3 {
+		t.Errorf("Values were wrong after delete: %v", vals)
+	}
+}
+
+func TestSetOrder(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	if err := om.SetOrder([]string{"three", "one", "two"}); err != nil {
+		t.Error("Error setting order: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "three,one,two" {
+		t.Errorf("Order was not set: %v", om.GetOrder())
+	}
+	if om.IndexOf("two") != 2 {
+		t.Error("Index of two was not updated after setting order")
+	}
+	if _, val, _ := om.GetIndex(0); val != 3 {
+		t.Error("Value at the front was wrong after setting order")
+	}
+
+	if err := om.SetOrder([]string{"one", "two"}); err == nil {
+		t.Error("No error was received when setting an order with too few keys.")
+	}
+	if err := om.SetOrder([]string{"one", "two", "four"}); err == nil {
+		t.Error("No error was received when setting an order with different keys.")
+	}
+	if strings.Join(om.GetOrder(), ",") != "three,one,two" {
+		t.Errorf("Order was changed by a failed SetOrder: %v", om.GetOrder())
+	}
+}
+
+func TestDeleteIndex(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	if err := om.DeleteIndex(1); err != nil {
+		t.Error("Error deleting index: " + err.Error())
+	}
+	if strings.Join(om.GetOrder(), ",") != "one,three" || om.Has("two") {
+		t.Errorf("Order was wrong after deleting: %v", om.GetOrder())
+	}
+	if err := om.DeleteIndex(2); err == nil {
+		t.Error("No error was received when deleting above the range.")
+	}
+	if err := om.DeleteIndex(-1); err == nil {
+		t.Error("No error was received when deleting a negative index.")
+	}
+}
+
+func TestClear(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+
+	om.Clear()
+	if om.Count() != 0 || om.Has("one") || om.IndexOf("two") != -1 {
+		t.Error("Map was not empty after clearing")
+	}
+	om.Add("three", 3)
+	if strings.Join(om.GetOrder(), ",") != "three" {
+		t.Errorf("Map could not be reused after clearing: %v", om.GetOrder())
+	}
+}
+
+func TestIterator(t *testing.T) {
+	om := NewTyped[TestData]()
+	om.Add("one", TestData{ID: 1, Name: "one"})
+	om.Add("two", TestData{ID: 2, Name: "two"})
+	om.Add("three", TestData{ID: 3, Name: "three"})
+
+	iter := om.Iterator()
+	i := 1
+	for data := range iter.Loop() {
+		if data.Val.ID != i {
+			t.Errorf("Iterator returned %v at position %d", data.Val, i)
+		}
+		i++
+	}
+	if i != 4 {
+		t.Error("Iterator did not return every item")
+	}
+
+	brk := om.Iterator()
+	for data := range brk.Loop() {
+		if data.Key == "two" {
+			brk.Break()
+			break
+		}
+	}
+}
+
+func TestIteratorBreakAfterCancel(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := om.IteratorContext(ctx)
+	for data := range it.Loop() {
+		if data.Key == "one" {
+			break
+		}
+	}
+	cancel()
+	it.Break()
+
+	done := om.Iterator()
+	for range done.Loop() {
+	}
+	done.Break()
+}
+
+func TestAll(t *testing.T) {
+	om := NewTyped[int]()
+	om.Add("one", 1)
+	om.Add("two", 2)
+	om.Add("three", 3)
+
+	keys := make([]string, 0)
+	for k, v := range om.All() {
+		keys = append(keys, k)
+		if v == 2 {
+			om.Delete("three")
+			break
+		}
+	}
+	if strings.Join(keys, ",") != "one,two" {
+		t.Errorf("All returned the wrong keys: %v", keys)
+	}
+	if om.Has("three") {
+		t.Error("Map could not be changed from inside All")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	om := NewTyped[int]()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				om.Set(strings.Repeat("k", i+1), j)
+				om.GetKey("k")
+				om.IndexOf("kk")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if om.Count() != 10 {
+		t.Error("Map does not contain correct number of items")
+	}
+}
@@ -0,0 +1,102 @@
+package orderedmap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+)
+
+// A single item in the XML form of an OrderedMap.
+type xmlEntry struct {
+	Key string `xml:"key,attr"`
+	Val string `xml:",chardata"`
+}
+
+// Encode the map as XML, implementing xml.Marshaler.  Each item becomes an
+// entry element, in order, with its key as an attribute and its value encoded
+// inside using the XML encoder:
+//
+// 	<map>
+// 		<entry key="one">1</entry>
+// 		<entry key="two">2</entry>
+// 	</map>
+//
+// The outer element is named map, unless the encoder asks for another name,
+// such as by a field's xml tag.  Slice, array, and map values would be split
+// across several entries, so an error is returned for them instead.
+func (m *OrderedMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	entries := m.tuples()
+	for _, t := range entries {
+		v := reflect.ValueOf(t.Val)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		split := v.Kind() == reflect.Map
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			// Byte slices are encoded as character data, so they are fine.
+			split = v.Type().Elem().Kind() != reflect.Uint8
+		}
+		if split {
+			return fmt.Errorf("Value for key %q is a %s, which cannot be encoded as a single XML entry.", t.Key, v.Kind())
+		}
+	}
+
+	if start.Name.Local == "OrderedMap" {
+		start.Name.Local = "map"
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, t := range entries {
+		entry := xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: t.Key}},
+		}
+		if t.Val == nil {
+			if err := e.EncodeToken(entry); err != nil {
+				return err
+			}
+			if err := e.EncodeToken(entry.End()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.EncodeElement(t.Val, entry); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Decode the map from XML, implementing xml.Unmarshaler.  Entries are added in
+// the order they appear in the document, replacing anything already in the
+// map.  Since XML holds no type information, every value is loaded as the
+// string of character data inside its entry.  It is safe to unmarshal into an
+// OrderedMap that was not created with New.
+func (m *OrderedMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	entries := make([]Tuple, 0)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "entry" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			var entry xmlEntry
+			if err := d.DecodeElement(&entry, &t); err != nil {
+				return err
+			}
+			entries = append(entries, Tuple{entry.Key, entry.Val})
+		case xml.EndElement:
+			m.load(entries)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package orderedmap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestMarshalXML(t *testing.T) {
+	om := New()
+	om.Add("zeta", 1)
+	om.Add("alpha", "two")
+	om.Add("mid", nil)
+
+	out, err := xml.Marshal(om)
+	if err != nil {
+		t.Fatal("Error marshalling map: " + err.Error())
+	}
+
+	expected := `<map><entry key="zeta">1</entry><entry key="alpha">two</entry><entry key="mid"></entry></map>`
+	if string(out) != expected {
+		t.Errorf("XML was wrong: %s", out)
+	}
+
+	var res OrderedMap
+	if err := xml.Unmarshal(out, &res); err != nil {
+		t.Fatal("Error unmarshalling map: " + err.Error())
+	}
+	if got := strings.Join(res.GetOrder(), ","); got != "zeta,alpha,mid" {
+		t.Errorf("Order did not survive a round trip: %s", got)
+	}
+	if val, _ := res.GetKey("zeta"); val != "1" {
+		t.Errorf("Value did not survive a round trip: %v", val)
+	}
+
+	wrapped := struct {
+		XMLName xml.Name    `xml:"config"`
+		Map     *OrderedMap `xml:"map"`
+	}{Map: om}
+	out, err = xml.Marshal(wrapped)
+	if err != nil {
+		t.Fatal("Error marshalling wrapped map: " + err.Error())
+	}
+	if !strings.HasPrefix(string(out), `<config><map><entry key="zeta">1</entry>`) {
+		t.Errorf("Wrapped XML was wrong: %s", out)
+	}
+}
+
+func TestMarshalXMLUnsupported(t *testing.T) {
+	for _, val := range []interface{}{[]int{1, 2}, [2]string{"a", "b"}, map[string]int{"a": 1}, &[]int{1}} {
+		om := New()
+		om.Add("ok", 1)
+		om.Add("bad", val)
+		if _, err := xml.Marshal(om); err == nil {
+			t.Errorf("No error was received when marshalling a %T value.", val)
+		}
+	}
+
+	om := New()
+	om.Add("bytes", []byte("raw"))
+	out, err := xml.Marshal(om)
+	if err != nil {
+		t.Fatal("Error marshalling a byte slice value: " + err.Error())
+	}
+	if string(out) != `<map><entry key="bytes">raw</entry></map>` {
+		t.Errorf("XML was wrong: %s", out)
+	}
+}